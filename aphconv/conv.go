@@ -0,0 +1,377 @@
+// Package aphconv converts between the nullable sql values, the protobuf well
+// known types and go pointers. The nullable values are handled through the
+// database/sql/driver interfaces, so that the sql.Null*, dat.Null* and
+// pgtype values are all supported.
+package aphconv
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/pkg/errors"
+)
+
+const (
+	// seconds of 0001-01-01T00:00:00Z, the minimum of google.protobuf.Timestamp
+	minTimestampSeconds = -62135596800
+	// seconds of 10000-01-01T00:00:00Z, the exclusive maximum of
+	// google.protobuf.Timestamp
+	maxTimestampSeconds = 253402300800
+	// maximum seconds of google.protobuf.Duration, about 10000 years
+	maxDurationSeconds = 315576000000
+)
+
+// ErrOutOfRange is the cause of the conversion errors of the timestamps and
+// the durations that are outside the range of the target type
+var ErrOutOfRange = errors.New("value is out of range")
+
+// TimeToTimestamp converts the time to a timestamp, it fails for a time
+// outside the range of 0001-01-01 to 9999-12-31
+func TimeToTimestamp(t time.Time) (*timestamp.Timestamp, error) {
+	ts := &timestamp.Timestamp{Seconds: t.Unix(), Nanos: int32(t.Nanosecond())}
+	if err := validateTimestamp(ts); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// TimestampToTime converts the timestamp to an UTC time, it fails for a nil
+// or an invalid timestamp
+func TimestampToTime(ts *timestamp.Timestamp) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, errors.New("timestamp is nil")
+	}
+	if err := validateTimestamp(ts); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+func validateTimestamp(ts *timestamp.Timestamp) error {
+	if ts.Seconds < minTimestampSeconds || ts.Seconds >= maxTimestampSeconds {
+		return errors.Wrapf(ErrOutOfRange, "timestamp with %d seconds", ts.Seconds)
+	}
+	if ts.Nanos < 0 || ts.Nanos >= 1e9 {
+		return errors.Wrapf(ErrOutOfRange, "timestamp with %d nanos", ts.Nanos)
+	}
+	return nil
+}
+
+// TimePtrToTimestamp converts the time pointer to a timestamp, nil is
+// converted to nil
+func TimePtrToTimestamp(t *time.Time) (*timestamp.Timestamp, error) {
+	if t == nil {
+		return nil, nil
+	}
+	return TimeToTimestamp(*t)
+}
+
+// TimestampToTimePtr converts the timestamp to a time pointer, nil is
+// converted to nil
+func TimestampToTimePtr(ts *timestamp.Timestamp) (*time.Time, error) {
+	if ts == nil {
+		return nil, nil
+	}
+	t, err := TimestampToTime(ts)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// StringToTimestamp parses a RFC 3339 time, the format in which the times
+// are usually kept in arangodb documents, to a timestamp. The empty string
+// is converted to nil.
+func StringToTimestamp(s string) (*timestamp.Timestamp, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse time %s %s", s, err)
+	}
+	return TimeToTimestamp(t)
+}
+
+// TimestampToString formats the timestamp as a RFC 3339 time, nil is
+// formatted as the empty string
+func TimestampToString(ts *timestamp.Timestamp) (string, error) {
+	if ts == nil {
+		return "", nil
+	}
+	t, err := TimestampToTime(ts)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.RFC3339Nano), nil
+}
+
+// DurationToProto converts the duration, every time.Duration is within the
+// range of google.protobuf.Duration
+func DurationToProto(d time.Duration) *duration.Duration {
+	nanos := d.Nanoseconds()
+	return &duration.Duration{
+		Seconds: nanos / 1e9,
+		Nanos:   int32(nanos % 1e9),
+	}
+}
+
+// ProtoToDuration converts the duration, it fails for a nil or an invalid
+// duration and for a duration that overflows time.Duration
+func ProtoToDuration(d *duration.Duration) (time.Duration, error) {
+	if d == nil {
+		return 0, errors.New("duration is nil")
+	}
+	if d.Seconds < -maxDurationSeconds || d.Seconds > maxDurationSeconds ||
+		d.Nanos <= -1e9 || d.Nanos >= 1e9 ||
+		(d.Seconds > 0 && d.Nanos < 0) || (d.Seconds < 0 && d.Nanos > 0) {
+		return 0, errors.Wrapf(ErrOutOfRange, "duration with %d seconds and %d nanos", d.Seconds, d.Nanos)
+	}
+	maxSeconds := int64(math.MaxInt64 / int64(time.Second))
+	td := time.Duration(d.Seconds)*time.Second + time.Duration(d.Nanos)
+	if d.Seconds > maxSeconds || d.Seconds < -maxSeconds ||
+		(d.Seconds > 0 && td < 0) || (d.Seconds < 0 && td > 0) {
+		return 0, errors.Wrapf(ErrOutOfRange, "duration with %d seconds overflows time.Duration", d.Seconds)
+	}
+	return td, nil
+}
+
+// DurationPtrToProto converts the duration pointer, nil is converted to nil
+func DurationPtrToProto(d *time.Duration) *duration.Duration {
+	if d == nil {
+		return nil
+	}
+	return DurationToProto(*d)
+}
+
+// ProtoToDurationPtr converts the duration to a pointer, nil is converted to
+// nil
+func ProtoToDurationPtr(d *duration.Duration) (*time.Duration, error) {
+	if d == nil {
+		return nil, nil
+	}
+	td, err := ProtoToDuration(d)
+	if err != nil {
+		return nil, err
+	}
+	return &td, nil
+}
+
+// driverValue returns the value of the nullable value, nil for an invalid
+// one
+func driverValue(v driver.Valuer) (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	dv, err := v.Value()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the value of %T %s", v, err)
+	}
+	return dv, nil
+}
+
+// ValueToStringValue converts a nullable value such as sql.NullString,
+// dat.NullString or pgtype.Text to a wrapper, the null value is converted to
+// nil
+func ValueToStringValue(v driver.Valuer) (*wrappers.StringValue, error) {
+	dv, err := driverValue(v)
+	if err != nil || dv == nil {
+		return nil, err
+	}
+	switch s := dv.(type) {
+	case string:
+		return &wrappers.StringValue{Value: s}, nil
+	case []byte:
+		return &wrappers.StringValue{Value: string(s)}, nil
+	}
+	return nil, fmt.Errorf("unable to convert %T to string", dv)
+}
+
+// ValueToInt64Value converts a nullable value such as sql.NullInt64,
+// dat.NullInt64 or pgtype.Int8 to a wrapper, the null value is converted to
+// nil
+func ValueToInt64Value(v driver.Valuer) (*wrappers.Int64Value, error) {
+	dv, err := driverValue(v)
+	if err != nil || dv == nil {
+		return nil, err
+	}
+	if i, ok := dv.(int64); ok {
+		return &wrappers.Int64Value{Value: i}, nil
+	}
+	return nil, fmt.Errorf("unable to convert %T to int64", dv)
+}
+
+// ValueToBoolValue converts a nullable value such as sql.NullBool,
+// dat.NullBool or pgtype.Bool to a wrapper, the null value is converted to
+// nil
+func ValueToBoolValue(v driver.Valuer) (*wrappers.BoolValue, error) {
+	dv, err := driverValue(v)
+	if err != nil || dv == nil {
+		return nil, err
+	}
+	if b, ok := dv.(bool); ok {
+		return &wrappers.BoolValue{Value: b}, nil
+	}
+	return nil, fmt.Errorf("unable to convert %T to bool", dv)
+}
+
+// ValueToDoubleValue converts a nullable value such as sql.NullFloat64,
+// dat.NullFloat64 or pgtype.Float8 to a wrapper, the null value is converted
+// to nil
+func ValueToDoubleValue(v driver.Valuer) (*wrappers.DoubleValue, error) {
+	dv, err := driverValue(v)
+	if err != nil || dv == nil {
+		return nil, err
+	}
+	if f, ok := dv.(float64); ok {
+		return &wrappers.DoubleValue{Value: f}, nil
+	}
+	return nil, fmt.Errorf("unable to convert %T to float64", dv)
+}
+
+// ValueToTimestamp converts a nullable value such as sql.NullTime,
+// dat.NullTime or pgtype.Timestamptz to a timestamp, the null value is
+// converted to nil
+func ValueToTimestamp(v driver.Valuer) (*timestamp.Timestamp, error) {
+	dv, err := driverValue(v)
+	if err != nil || dv == nil {
+		return nil, err
+	}
+	if t, ok := dv.(time.Time); ok {
+		return TimeToTimestamp(t)
+	}
+	return nil, fmt.Errorf("unable to convert %T to time", dv)
+}
+
+// scan sets the nullable destination, nil sets it to null
+func scan(dst sql.Scanner, v interface{}) error {
+	if err := dst.Scan(v); err != nil {
+		return fmt.Errorf("unable to set the value of %T %s", dst, err)
+	}
+	return nil
+}
+
+// ScanStringValue sets a nullable destination such as *sql.NullString,
+// *dat.NullString or *pgtype.Text from the wrapper, nil sets it to null
+func ScanStringValue(dst sql.Scanner, v *wrappers.StringValue) error {
+	if v == nil {
+		return scan(dst, nil)
+	}
+	return scan(dst, v.Value)
+}
+
+// ScanInt64Value sets a nullable destination such as *sql.NullInt64,
+// *dat.NullInt64 or *pgtype.Int8 from the wrapper, nil sets it to null
+func ScanInt64Value(dst sql.Scanner, v *wrappers.Int64Value) error {
+	if v == nil {
+		return scan(dst, nil)
+	}
+	return scan(dst, v.Value)
+}
+
+// ScanBoolValue sets a nullable destination such as *sql.NullBool,
+// *dat.NullBool or *pgtype.Bool from the wrapper, nil sets it to null
+func ScanBoolValue(dst sql.Scanner, v *wrappers.BoolValue) error {
+	if v == nil {
+		return scan(dst, nil)
+	}
+	return scan(dst, v.Value)
+}
+
+// ScanDoubleValue sets a nullable destination such as *sql.NullFloat64,
+// *dat.NullFloat64 or *pgtype.Float8 from the wrapper, nil sets it to null
+func ScanDoubleValue(dst sql.Scanner, v *wrappers.DoubleValue) error {
+	if v == nil {
+		return scan(dst, nil)
+	}
+	return scan(dst, v.Value)
+}
+
+// ScanTimestamp sets a nullable destination such as *sql.NullTime,
+// *dat.NullTime or *pgtype.Timestamptz from the timestamp, nil sets it to
+// null
+func ScanTimestamp(dst sql.Scanner, ts *timestamp.Timestamp) error {
+	if ts == nil {
+		return scan(dst, nil)
+	}
+	t, err := TimestampToTime(ts)
+	if err != nil {
+		return err
+	}
+	return scan(dst, t)
+}
+
+// StringPtrToValue converts the pointer to a wrapper, nil is converted to nil
+func StringPtrToValue(s *string) *wrappers.StringValue {
+	if s == nil {
+		return nil
+	}
+	return &wrappers.StringValue{Value: *s}
+}
+
+// StringValueToPtr converts the wrapper to a pointer, nil is converted to nil
+func StringValueToPtr(v *wrappers.StringValue) *string {
+	if v == nil {
+		return nil
+	}
+	s := v.Value
+	return &s
+}
+
+// Int64PtrToValue converts the pointer to a wrapper, nil is converted to nil
+func Int64PtrToValue(i *int64) *wrappers.Int64Value {
+	if i == nil {
+		return nil
+	}
+	return &wrappers.Int64Value{Value: *i}
+}
+
+// Int64ValueToPtr converts the wrapper to a pointer, nil is converted to nil
+func Int64ValueToPtr(v *wrappers.Int64Value) *int64 {
+	if v == nil {
+		return nil
+	}
+	i := v.Value
+	return &i
+}
+
+// BoolPtrToValue converts the pointer to a wrapper, nil is converted to nil
+func BoolPtrToValue(b *bool) *wrappers.BoolValue {
+	if b == nil {
+		return nil
+	}
+	return &wrappers.BoolValue{Value: *b}
+}
+
+// BoolValueToPtr converts the wrapper to a pointer, nil is converted to nil
+func BoolValueToPtr(v *wrappers.BoolValue) *bool {
+	if v == nil {
+		return nil
+	}
+	b := v.Value
+	return &b
+}
+
+// Float64PtrToValue converts the pointer to a wrapper, nil is converted to
+// nil
+func Float64PtrToValue(f *float64) *wrappers.DoubleValue {
+	if f == nil {
+		return nil
+	}
+	return &wrappers.DoubleValue{Value: *f}
+}
+
+// DoubleValueToPtr converts the wrapper to a pointer, nil is converted to nil
+func DoubleValueToPtr(v *wrappers.DoubleValue) *float64 {
+	if v == nil {
+		return nil
+	}
+	f := v.Value
+	return &f
+}
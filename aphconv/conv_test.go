@@ -0,0 +1,115 @@
+package aphconv
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/pkg/errors"
+)
+
+func TestTimestamp(t *testing.T) {
+	now := time.Date(2019, 3, 4, 5, 6, 7, 8, time.UTC)
+	ts, err := TimeToTimestamp(now)
+	if err != nil {
+		t.Fatalf("error in converting time %s", err)
+	}
+	rt, err := TimestampToTime(ts)
+	if err != nil {
+		t.Fatalf("error in converting timestamp %s", err)
+	}
+	if !rt.Equal(now) {
+		t.Fatalf("expected time %s, got %s", now, rt)
+	}
+	for _, tm := range []time.Time{
+		time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(0, 12, 31, 0, 0, 0, 0, time.UTC),
+	} {
+		if _, err := TimeToTimestamp(tm); errors.Cause(err) != ErrOutOfRange {
+			t.Fatalf("expected out of range error for %s, got %v", tm, err)
+		}
+	}
+	if _, err := TimestampToTime(&timestamp.Timestamp{Nanos: 1e9}); errors.Cause(err) != ErrOutOfRange {
+		t.Fatalf("expected out of range error for nanos, got %v", err)
+	}
+	if _, err := TimestampToTime(nil); err == nil {
+		t.Fatal("expected error for nil timestamp")
+	}
+	s, err := TimestampToString(ts)
+	if err != nil || s != "2019-03-04T05:06:07.000000008Z" {
+		t.Fatalf("unexpected time string %s %v", s, err)
+	}
+	pts, err := StringToTimestamp(s)
+	if err != nil || pts.Seconds != ts.Seconds || pts.Nanos != ts.Nanos {
+		t.Fatalf("unexpected timestamp %v %v", pts, err)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for _, d := range []time.Duration{0, 1500 * time.Millisecond, -2500 * time.Millisecond} {
+		rd, err := ProtoToDuration(DurationToProto(d))
+		if err != nil {
+			t.Fatalf("error in converting duration %s", err)
+		}
+		if rd != d {
+			t.Fatalf("expected duration %s, got %s", d, rd)
+		}
+	}
+	for _, d := range []*duration.Duration{
+		{Seconds: 1e10},
+		{Seconds: 1, Nanos: -1},
+		{Seconds: 315576000001},
+	} {
+		if _, err := ProtoToDuration(d); errors.Cause(err) != ErrOutOfRange {
+			t.Fatalf("expected out of range error for %v, got %v", d, err)
+		}
+	}
+}
+
+func TestNullValues(t *testing.T) {
+	sv, err := ValueToStringValue(sql.NullString{String: "", Valid: true})
+	if err != nil || sv == nil || sv.Value != "" {
+		t.Fatalf("expected empty string value, got %v %v", sv, err)
+	}
+	sv, err = ValueToStringValue(sql.NullString{})
+	if err != nil || sv != nil {
+		t.Fatalf("expected nil for null string, got %v %v", sv, err)
+	}
+	iv, err := ValueToInt64Value(sql.NullInt64{Int64: 7, Valid: true})
+	if err != nil || iv.GetValue() != 7 {
+		t.Fatalf("expected int64 value 7, got %v %v", iv, err)
+	}
+	if _, err := ValueToBoolValue(sql.NullInt64{Int64: 7, Valid: true}); err == nil {
+		t.Fatal("expected error for converting int64 to bool")
+	}
+	var ns sql.NullString
+	if err := ScanStringValue(&ns, &wrappers.StringValue{Value: "dicty"}); err != nil {
+		t.Fatalf("error in scanning string value %s", err)
+	}
+	if !ns.Valid || ns.String != "dicty" {
+		t.Fatalf("unexpected null string %v", ns)
+	}
+	if err := ScanStringValue(&ns, nil); err != nil || ns.Valid {
+		t.Fatalf("expected null string, got %v %v", ns, err)
+	}
+	var nb sql.NullBool
+	if err := ScanBoolValue(&nb, &wrappers.BoolValue{Value: false}); err != nil || !nb.Valid || nb.Bool {
+		t.Fatalf("expected valid false, got %v %v", nb, err)
+	}
+}
+
+func TestPointers(t *testing.T) {
+	if StringValueToPtr(nil) != nil || StringPtrToValue(nil) != nil {
+		t.Fatal("expected nil for nil")
+	}
+	zero := int64(0)
+	if v := Int64PtrToValue(&zero); v == nil || v.Value != 0 {
+		t.Fatalf("expected zero value, got %v", v)
+	}
+	if p := BoolValueToPtr(&wrappers.BoolValue{}); p == nil || *p {
+		t.Fatalf("expected pointer to false, got %v", p)
+	}
+}
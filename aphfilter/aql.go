@@ -0,0 +1,137 @@
+package aphfilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// AQLRenderer renders filters to AQL(arangodb query language) conditions
+// with bind variables(@filter1, @filter2 ...) for the values
+type AQLRenderer struct {
+	// Mapping between filter fields and document attributes
+	Fields map[string]string
+	// Prefix of the bind variable names, defaults to filter
+	Prefix string
+	// Inline writes the values as AQL literals instead of bind variables
+	Inline bool
+	vars   map[string]interface{}
+}
+
+// NewAQLRenderer is the constructor for AQLRenderer
+func NewAQLRenderer(fields map[string]string) *AQLRenderer {
+	return &AQLRenderer{
+		Fields: fields,
+		Prefix: "filter",
+		vars:   make(map[string]interface{}),
+	}
+}
+
+// BindVars returns the bind variables of all the rendered conditions
+func (r *AQLRenderer) BindVars() map[string]interface{} {
+	return r.vars
+}
+
+// Condition generates the AQL condition of the filter
+func (r *AQLRenderer) Condition(f *Filter) (string, error) {
+	attr, err := lookupField(r.Fields, f.Field)
+	if err != nil {
+		return "", err
+	}
+	var v string
+	switch f.Operator {
+	case Null:
+		if f.Value == "true" {
+			return fmt.Sprintf("%s == null", attr), nil
+		}
+		return fmt.Sprintf("%s != null", attr), nil
+	case In, NotIn:
+		values := make([]interface{}, len(f.Values))
+		for i, fv := range f.Values {
+			values[i] = TypedValue(f, fv)
+		}
+		if v, err = r.bind(values); err != nil {
+			return "", err
+		}
+		op := "IN"
+		if f.Operator == NotIn {
+			op = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s %s", attr, op, v), nil
+	case Contains, NotContains:
+		if v, err = r.bind(fmt.Sprintf("%%%s%%", escapeLike(f.Value))); err != nil {
+			return "", err
+		}
+		cond := fmt.Sprintf("LIKE(%s, %s, true)", attr, v)
+		if f.Operator == NotContains {
+			cond = "NOT " + cond
+		}
+		return cond, nil
+	case Match, NotMatch:
+		if v, err = r.bind(f.Value); err != nil {
+			return "", err
+		}
+		op := "=~"
+		if f.Operator == NotMatch {
+			op = "!~"
+		}
+		return fmt.Sprintf("%s %s %s", attr, op, v), nil
+	case StrictEqual, StrictNotEqual:
+		if v, err = r.bind(f.Value); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s %s", attr, strings.TrimSuffix(string(f.Operator), "="), v), nil
+	case Equal, NotEqual, Greater, GreaterOrEqual, Less, LessOrEqual:
+		if v, err = r.bind(TypedValue(f, f.Value)); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s %s", attr, f.Operator, v), nil
+	case DateEqual, DateGreater, DateGreaterOrEqual, DateLess, DateLessOrEqual:
+		if _, err := ParseDate(f.Value); err != nil {
+			return "", err
+		}
+		if v, err = r.bind(f.Value); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(
+			"%s %s DATE_ISO8601(%s)",
+			attr, strings.TrimPrefix(string(f.Operator), "$"), v,
+		), nil
+	}
+	return "", fmt.Errorf("filter operator %s is not supported", f.Operator)
+}
+
+// bind stores the value as bind variable and returns its reference, for
+// inline rendering the value is returned as AQL literal
+func (r *AQLRenderer) bind(value interface{}) (string, error) {
+	if r.Inline {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("unable to convert %v to AQL literal %s", value, err)
+		}
+		return string(b), nil
+	}
+	if r.vars == nil {
+		r.vars = make(map[string]interface{})
+	}
+	prefix := r.Prefix
+	if len(prefix) == 0 {
+		prefix = "filter"
+	}
+	name := fmt.Sprintf("%s%d", prefix, len(r.vars)+1)
+	r.vars[name] = value
+	return "@" + name, nil
+}
+
+// escapeLike escapes the wildcard characters of AQL LIKE function
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ToAQL generates the AQL condition along with its bind variables from the
+// expression
+func ToAQL(fields map[string]string, expr *Expr) (string, map[string]interface{}, error) {
+	r := NewAQLRenderer(fields)
+	clause, err := Render(expr, r)
+	return clause, r.BindVars(), err
+}
@@ -0,0 +1,263 @@
+// Package aphfilter parses the filter query parameter to a backend neutral
+// expression tree, which is then rendered to a storage specific query
+// condition by a Renderer.
+package aphfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operator is a comparison operator of a filter
+type Operator string
+
+const (
+	// Equal matches identical values
+	Equal Operator = "=="
+	// NotEqual excludes identical values
+	NotEqual Operator = "!="
+	// StrictEqual matches identical values compared as strings
+	StrictEqual Operator = "==="
+	// StrictNotEqual excludes identical values compared as strings
+	StrictNotEqual Operator = "!=="
+	// Contains matches values containing the string, ignoring case
+	Contains Operator = "=@"
+	// NotContains excludes values containing the string, ignoring case
+	NotContains Operator = "!@"
+	// Match matches values with the regular expression
+	Match Operator = "~"
+	// NotMatch excludes values matching the regular expression
+	NotMatch Operator = "!~"
+	// Greater matches larger values
+	Greater Operator = ">"
+	// GreaterOrEqual matches larger or identical values
+	GreaterOrEqual Operator = ">="
+	// Less matches smaller values
+	Less Operator = "<"
+	// LessOrEqual matches smaller or identical values
+	LessOrEqual Operator = "<="
+	// DateEqual matches identical dates
+	DateEqual Operator = "$=="
+	// DateGreater matches later dates
+	DateGreater Operator = "$>"
+	// DateGreaterOrEqual matches later or identical dates
+	DateGreaterOrEqual Operator = "$>="
+	// DateLess matches earlier dates
+	DateLess Operator = "$<"
+	// DateLessOrEqual matches earlier or identical dates
+	DateLessOrEqual Operator = "$<="
+	// In matches any value of a list
+	In Operator = "=in="
+	// NotIn excludes every value of a list
+	NotIn Operator = "=out="
+	// Null matches absent values for true and present values for false
+	Null Operator = "=null="
+)
+
+// Operators returns all the supported operators
+func Operators() []Operator {
+	return []Operator{
+		Equal, NotEqual, StrictEqual, StrictNotEqual,
+		Contains, NotContains, Match, NotMatch,
+		Greater, GreaterOrEqual, Less, LessOrEqual,
+		DateEqual, DateGreater, DateGreaterOrEqual, DateLess, DateLessOrEqual,
+		In, NotIn, Null,
+	}
+}
+
+// IsDate reports whether the operator compares dates
+func (o Operator) IsDate() bool {
+	return strings.HasPrefix(string(o), "$")
+}
+
+// IsList reports whether the operator takes a list of values
+func (o Operator) IsList() bool {
+	return o == In || o == NotIn
+}
+
+// Logic combines filter expressions
+type Logic string
+
+const (
+	// And combines filter expressions by conjunction
+	And Logic = "AND"
+	// Or combines filter expressions by disjunction
+	Or Logic = "OR"
+)
+
+// regex to capture all variations of date string
+// https://play.golang.org/p/NzeBmlQh13v
+var dre = regexp.MustCompile(`^\d{4}\-(0[1-9]|1[012])$|^\d{4}$|^\d{4}\-(0[1-9]|1[012])\-(0[1-9]|[12][0-9]|3[01])$`)
+
+// Filter is a single filter condition
+type Filter struct {
+	// Field of the resource on which the filter will be applied
+	Field string
+	// Type of filter for matching or exclusion
+	Operator Operator
+	// The value to match or exclude, members of a list are comma separated
+	Value string
+	// Members of the list for the In and NotIn operators
+	Values []string
+	// Whether the value was given as a quoted string
+	Quoted bool
+	// Separator(, or ;) that follows the filter in the filter string, empty
+	// for the last one
+	Logic string
+}
+
+// Expr is a node of the filter expression tree. It is either a leaf holding
+// a single filter or a group of expressions combined by a logic operator.
+type Expr struct {
+	// Logic operator of a group
+	Logic Logic
+	// Expressions of a group
+	Children []*Expr
+	// Filter of a leaf
+	Filter *Filter
+}
+
+// IsLeaf reports whether the expression holds a single filter
+func (e *Expr) IsLeaf() bool {
+	return e != nil && e.Filter != nil
+}
+
+// IsEmpty reports whether the expression has no filter, which is true for a
+// nil expression
+func (e *Expr) IsEmpty() bool {
+	return len(e.Leaves()) == 0
+}
+
+// Leaves returns all the filters of the expression tree in the order they
+// appear in the filter string
+func (e *Expr) Leaves() []*Filter {
+	if e == nil {
+		return nil
+	}
+	if e.IsLeaf() {
+		return []*Filter{e.Filter}
+	}
+	var filters []*Filter
+	for _, c := range e.Children {
+		filters = append(filters, c.Leaves()...)
+	}
+	return filters
+}
+
+// FromList builds an expression tree from a flat list of filters combined by
+// their Logic separators, where ; (AND) has a higher precedence than , (OR).
+func FromList(filters []*Filter) *Expr {
+	or := &Expr{Logic: Or}
+	and := &Expr{Logic: And}
+	for _, f := range filters {
+		and.Children = append(and.Children, &Expr{Filter: f})
+		if f.Logic != ";" {
+			or.Children = append(or.Children, collapse(and))
+			and = &Expr{Logic: And}
+		}
+	}
+	if len(and.Children) > 0 {
+		or.Children = append(or.Children, collapse(and))
+	}
+	return collapse(or)
+}
+
+// IsGrouped reports whether the expression has groups whose meaning is lost
+// when its filters are combined as a flat list by their Logic separators,
+// which is true for groups that override the precedence of AND over OR.
+func (e *Expr) IsGrouped() bool {
+	return !sameExpr(flatten(e), flatten(FromList(e.Leaves())))
+}
+
+// flatten merges the nested groups having the same logic operator as their
+// parent, which does not change the meaning of the expression
+func flatten(e *Expr) *Expr {
+	if e == nil || e.IsLeaf() {
+		return e
+	}
+	flat := &Expr{Logic: e.Logic}
+	for _, c := range e.Children {
+		c = flatten(c)
+		if !c.IsLeaf() && c.Logic == e.Logic {
+			flat.Children = append(flat.Children, c.Children...)
+			continue
+		}
+		flat.Children = append(flat.Children, c)
+	}
+	return flat
+}
+
+func sameExpr(a, b *Expr) bool {
+	if a.IsEmpty() || b.IsEmpty() {
+		return a.IsEmpty() == b.IsEmpty()
+	}
+	if a.IsLeaf() || b.IsLeaf() {
+		return a.IsLeaf() && b.IsLeaf() && a.Filter == b.Filter
+	}
+	if a.Logic != b.Logic || len(a.Children) != len(b.Children) {
+		return false
+	}
+	for i := range a.Children {
+		if !sameExpr(a.Children[i], b.Children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func collapse(e *Expr) *Expr {
+	if len(e.Children) == 1 {
+		return e.Children[0]
+	}
+	return e
+}
+
+// TypedValue converts an unquoted value to an integer, float, or time
+// whichever matches first, quoted or unmatched values stay as string
+func TypedValue(f *Filter, v string) interface{} {
+	if f.Quoted {
+		return v
+	}
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return i
+	}
+	if isNumeric(v) {
+		if fl, err := strconv.ParseFloat(v, 64); err == nil {
+			return fl
+		}
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t
+		}
+	}
+	return v
+}
+
+// isNumeric excludes the inf and nan values that are accepted by
+// strconv.ParseFloat
+func isNumeric(v string) bool {
+	for _, r := range v {
+		if !strings.ContainsRune("0123456789+-.eE", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseDate validates and parses the value of date operators, which is
+// either a year(2006), a month(2006-01) or a day(2006-01-02)
+func ParseDate(v string) (time.Time, error) {
+	if !dre.MatchString(v) {
+		return time.Time{}, fmt.Errorf("invalid date %s", v)
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("could not parse date %s", v)
+}
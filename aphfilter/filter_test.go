@@ -0,0 +1,229 @@
+package aphfilter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var columns = map[string]string{
+	"name":       "name",
+	"label":      "label",
+	"created_at": "created_at",
+	"count":      "count",
+	"status":     "status",
+}
+
+var attrs = map[string]string{
+	"name":       "doc.name",
+	"label":      "doc.label",
+	"created_at": "doc.created_at",
+	"count":      "doc.count",
+	"status":     "doc.status",
+}
+
+func TestParseGrouping(t *testing.T) {
+	expr, err := Parse("(name==a,label==b);count>3")
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	if expr.Logic != And || len(expr.Children) != 2 {
+		t.Fatalf("expected AND group of two expressions, got %s group of %d", expr.Logic, len(expr.Children))
+	}
+	if expr.Children[0].Logic != Or {
+		t.Fatalf("expected nested OR group, got %s", expr.Children[0].Logic)
+	}
+	leaves := expr.Leaves()
+	if len(leaves) != 3 {
+		t.Fatalf("expected 3 filters, got %d", len(leaves))
+	}
+	if leaves[0].Logic != "," || leaves[1].Logic != ";" || leaves[2].Logic != "" {
+		t.Fatalf("unexpected separators %q %q %q", leaves[0].Logic, leaves[1].Logic, leaves[2].Logic)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	cases := map[string]*Filter{
+		`label=="Dicty Stock Center"`: {Field: "label", Operator: Equal, Value: "Dicty Stock Center", Quoted: true},
+		`label=="say \"hi\" \\o/"`:    {Field: "label", Operator: Equal, Value: `say "hi" \o/`, Quoted: true},
+		`name==sadA-1.2`:              {Field: "name", Operator: Equal, Value: "sadA-1.2"},
+		`name=@jane.doe@example.org`:  {Field: "name", Operator: Contains, Value: "jane.doe@example.org"},
+		`name===Ünïcödé`:              {Field: "name", Operator: StrictEqual, Value: "Ünïcödé"},
+		`created_at$>=2019-01`:        {Field: "created_at", Operator: DateGreaterOrEqual, Value: "2019-01"},
+		`status=in=(a, "b c")`:        {Field: "status", Operator: In, Value: "a,b c", Values: []string{"a", "b c"}, Quoted: true},
+		`status=null=false`:           {Field: "status", Operator: Null, Value: "false"},
+	}
+	for fstr, exf := range cases {
+		expr, err := Parse(fstr)
+		if err != nil {
+			t.Fatalf("error in parsing filter %s %s", fstr, err)
+		}
+		if !reflect.DeepEqual(expr.Filter, exf) {
+			t.Fatalf("expected filter %+v actual %+v", exf, expr.Filter)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]int{
+		`name=="open`:          6,
+		`name==a b`:            8,
+		`(name==a`:             8,
+		`name=a`:               4,
+		`läbel==a`:             1,
+		`name==ä;`:             8,
+		`status=null=maybe`:    12,
+		`created_at$==2019-13`: 13,
+	}
+	for fstr, offset := range cases {
+		_, err := Parse(fstr)
+		if err == nil {
+			t.Fatalf("expected error in parsing filter %s", fstr)
+		}
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("expected syntax error for %s, got %T", fstr, err)
+		}
+		if serr.Offset != offset {
+			t.Fatalf("expected offset %d actual %d for %s", offset, serr.Offset, fstr)
+		}
+	}
+}
+
+func TestFromList(t *testing.T) {
+	expr, err := Parse("name==a;label==b,count>3")
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	if !reflect.DeepEqual(FromList(expr.Leaves()), expr) {
+		t.Fatal("expression built from list does not match the parsed one")
+	}
+}
+
+func TestIsGrouped(t *testing.T) {
+	cases := map[string]bool{
+		"name==a":                                false,
+		"name==a;label==b,count>3":               false,
+		"(name==a;label==b),count>3":             false,
+		"name==a;(label==b;count>3)":             false,
+		"(name==a)":                              false,
+		"(name==a,label==b);count>3":             true,
+		"name==a;(label==b,count>3)":             true,
+		"name==a,(label==b;count>3)":             false,
+		"(name==a,label==b),count>3":             false,
+		"((name==a,label==b);status==c),count>3": true,
+	}
+	for fstr, grouped := range cases {
+		expr, err := Parse(fstr)
+		if err != nil {
+			t.Fatalf("error in parsing filter %s %s", fstr, err)
+		}
+		if expr.IsGrouped() != grouped {
+			t.Fatalf("expected grouped %t for filter %s", grouped, fstr)
+		}
+	}
+	var expr *Expr
+	if expr.IsGrouped() {
+		t.Fatal("expected nil expression not to be grouped")
+	}
+}
+
+func TestToPostgres(t *testing.T) {
+	expr, err := Parse(`(name=@x.y,label=="a b");count>=3;status=out=(1,2);created_at=null=true`)
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	clause, args, err := ToPostgres(columns, expr)
+	if err != nil {
+		t.Fatalf("error in rendering filter %s", err)
+	}
+	exclause := "(name ~* $1 OR label = $2) AND count >= $3 AND status NOT IN ($4, $5) AND created_at IS NULL"
+	if clause != exclause {
+		t.Fatalf("expected clause %s actual %s", exclause, clause)
+	}
+	exargs := []interface{}{`.*x\.y.*`, "a b", int64(3), int64(1), int64(2)}
+	if !reflect.DeepEqual(args, exargs) {
+		t.Fatalf("expected bind values %v actual %v", exargs, args)
+	}
+}
+
+func TestToPostgresDate(t *testing.T) {
+	expr, err := Parse(`created_at$<2019-02-03`)
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	clause, args, err := ToPostgres(columns, expr)
+	if err != nil {
+		t.Fatalf("error in rendering filter %s", err)
+	}
+	if clause != "created_at < $1" {
+		t.Fatalf("unexpected clause %s", clause)
+	}
+	exdate := time.Date(2019, 2, 3, 0, 0, 0, 0, time.UTC)
+	if d, ok := args[0].(time.Time); !ok || !d.Equal(exdate) {
+		t.Fatalf("expected bind value %s actual %v", exdate, args[0])
+	}
+}
+
+func TestToAQL(t *testing.T) {
+	expr, err := Parse(`name!@"50%";count>2,status=in=(a,b);created_at$>=2019`)
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	clause, vars, err := ToAQL(attrs, expr)
+	if err != nil {
+		t.Fatalf("error in rendering filter %s", err)
+	}
+	exclause := "(NOT LIKE(doc.name, @filter1, true) AND doc.count > @filter2) OR (doc.status IN @filter3 AND doc.created_at >= DATE_ISO8601(@filter4))"
+	if clause != exclause {
+		t.Fatalf("expected clause %s actual %s", exclause, clause)
+	}
+	exvars := map[string]interface{}{
+		"filter1": `%50\%%`,
+		"filter2": int64(2),
+		"filter3": []interface{}{"a", "b"},
+		"filter4": "2019",
+	}
+	if !reflect.DeepEqual(vars, exvars) {
+		t.Fatalf("expected bind vars %v actual %v", exvars, vars)
+	}
+}
+
+func TestAQLInline(t *testing.T) {
+	expr, err := Parse(`name==="x'y";count<5`)
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	r := NewAQLRenderer(attrs)
+	r.Inline = true
+	clause, err := Render(expr, r)
+	if err != nil {
+		t.Fatalf("error in rendering filter %s", err)
+	}
+	exclause := `doc.name == "x'y" AND doc.count < 5`
+	if clause != exclause {
+		t.Fatalf("expected clause %s actual %s", exclause, clause)
+	}
+}
+
+func TestUnmappedField(t *testing.T) {
+	expr, err := Parse("unknown==1")
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	if _, _, err := ToPostgres(columns, expr); err == nil {
+		t.Fatal("expected error for unmapped filter field")
+	}
+}
+
+func TestEmptyExpr(t *testing.T) {
+	for _, expr := range []*Expr{nil, FromList(nil)} {
+		if !expr.IsEmpty() {
+			t.Fatalf("expected empty expression %v", expr)
+		}
+		clause, args, err := ToPostgres(columns, expr)
+		if err != nil || len(clause) != 0 || len(args) != 0 {
+			t.Fatalf("expected empty clause, got %s %v %v", clause, args, err)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package aphfilter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SyntaxError is returned for a filter string that cannot be parsed
+type SyntaxError struct {
+	// Character(not byte) offset in the filter string where the error occurred
+	Offset int
+	// Description of the error
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Offset, e.Msg)
+}
+
+// Parse parses the filter string to an expression tree. Filters are combined
+// with ; (AND) and , (OR), where AND has a higher precedence than OR.
+// Parentheses group expressions to override the precedence, for example
+// (a==1,b==2);c==3
+//
+// A value has to be double quoted when it contains whitespace or any of the
+// ,;()" characters, for example name=="Dicty Stock Center". Within quotes \"
+// and \\ are the only escape sequences.
+//
+// The =in= and =out= operators match against a parenthesized list, for
+// example status=in=(active,"on hold"), and =null=true or =null=false checks
+// for the absence or presence of a value.
+func Parse(fstr string) (*Expr, error) {
+	p := &parser{input: fstr}
+	expr, err := p.parseOr()
+	if err != nil {
+		return expr, err
+	}
+	if !p.eof() {
+		return expr, p.errorf("unexpected character %q", p.peekRune())
+	}
+	return expr, nil
+}
+
+type parser struct {
+	input string
+	pos   int
+	// last parsed filter, its Logic is set from the separator that follows
+	last *Filter
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() byte {
+	return p.input[p.pos]
+}
+
+func (p *parser) peekRune() rune {
+	r, _ := utf8.DecodeRuneInString(p.input[p.pos:])
+	return r
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{
+		Offset: utf8.RuneCountInString(p.input[:p.pos]),
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *parser) parseOr() (*Expr, error) {
+	return p.parseGroup(',', Or, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (*Expr, error) {
+	return p.parseGroup(';', And, p.parsePrimary)
+}
+
+// parseGroup parses one or more expressions separated by sep, a group node
+// is created only when there is more than one expression
+func (p *parser) parseGroup(sep byte, logic Logic, next func() (*Expr, error)) (*Expr, error) {
+	expr, err := next()
+	if err != nil {
+		return expr, err
+	}
+	children := []*Expr{expr}
+	for p.skipSpace(); !p.eof() && p.peek() == sep; p.skipSpace() {
+		p.last.Logic = string(sep)
+		p.pos++
+		expr, err := next()
+		if err != nil {
+			return expr, err
+		}
+		children = append(children, expr)
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &Expr{Logic: logic, Children: children}, nil
+}
+
+func (p *parser) parsePrimary() (*Expr, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("unexpected end of filter")
+	}
+	if p.peek() != '(' {
+		return p.parseFilter()
+	}
+	p.pos++
+	expr, err := p.parseOr()
+	if err != nil {
+		return expr, err
+	}
+	p.skipSpace()
+	if p.eof() || p.peek() != ')' {
+		return expr, p.errorf("missing closing parenthesis")
+	}
+	p.pos++
+	return expr, nil
+}
+
+func (p *parser) parseFilter() (*Expr, error) {
+	field := p.scanWord()
+	if len(field) == 0 {
+		return nil, p.errorf("expected filter field")
+	}
+	p.skipSpace()
+	op := p.scanOperator()
+	if len(op) == 0 {
+		return nil, p.errorf("expected filter operator after %s", field)
+	}
+	p.skipSpace()
+	f := &Filter{Field: field, Operator: op}
+	start := p.pos
+	if op.IsList() {
+		if err := p.parseList(f); err != nil {
+			return nil, err
+		}
+	} else {
+		value, quoted, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		f.Value = value
+		f.Quoted = quoted
+	}
+	switch {
+	case op == Null && f.Value != "true" && f.Value != "false":
+		p.pos = start
+		return nil, p.errorf("expected true or false for null check of %s", field)
+	case op.IsDate():
+		if _, err := ParseDate(f.Value); err != nil {
+			p.pos = start
+			return nil, p.errorf("%s", err)
+		}
+	}
+	p.last = f
+	return &Expr{Filter: f}, nil
+}
+
+// parseValue consumes a quoted or an unquoted value
+func (p *parser) parseValue() (string, bool, error) {
+	if !p.eof() && p.peek() == '"' {
+		value, err := p.scanQuoted()
+		return value, true, err
+	}
+	value := p.scanValue()
+	if len(value) == 0 {
+		return value, false, p.errorf("expected filter value")
+	}
+	return value, false, nil
+}
+
+// parseList consumes a parenthesized and comma separated list of values, for
+// example (a,b,"c d"). The list is considered quoted if any of its members
+// is quoted.
+func (p *parser) parseList(f *Filter) error {
+	if p.eof() || p.peek() != '(' {
+		return p.errorf("expected ( to start the list of %s", f.Field)
+	}
+	p.pos++
+	for {
+		p.skipSpace()
+		value, quoted, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		f.Values = append(f.Values, value)
+		f.Quoted = f.Quoted || quoted
+		p.skipSpace()
+		if p.eof() {
+			return p.errorf("missing closing parenthesis of list")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			f.Value = strings.Join(f.Values, ",")
+			return nil
+		default:
+			return p.errorf("unexpected character %q in list", p.peekRune())
+		}
+	}
+}
+
+func (p *parser) scanWord() string {
+	start := p.pos
+	for !p.eof() && isWordChar(p.peek()) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// scanOperator consumes the longest operator at the current position
+func (p *parser) scanOperator() Operator {
+	var match Operator
+	for _, op := range Operators() {
+		if strings.HasPrefix(p.input[p.pos:], string(op)) && len(op) > len(match) {
+			match = op
+		}
+	}
+	p.pos += len(match)
+	return match
+}
+
+// scanValue consumes an unquoted value, which runs until whitespace or any
+// of the reserved characters
+func (p *parser) scanValue() string {
+	start := p.pos
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if unicode.IsSpace(r) || strings.ContainsRune(`,;()"`, r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.input[start:p.pos]
+}
+
+// scanQuoted consumes a double quoted value and returns it unescaped
+func (p *parser) scanQuoted() (string, error) {
+	start := p.pos
+	p.pos++
+	var value strings.Builder
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if r == utf8.RuneError && size == 1 {
+			return "", p.errorf("invalid utf-8 encoding")
+		}
+		switch r {
+		case '"':
+			p.pos += size
+			return value.String(), nil
+		case '\\':
+			p.pos += size
+			if p.eof() || (p.peek() != '"' && p.peek() != '\\') {
+				return "", p.errorf("invalid escape sequence")
+			}
+			value.WriteByte(p.peek())
+			p.pos++
+		default:
+			value.WriteRune(r)
+			p.pos += size
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated quoted value")
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
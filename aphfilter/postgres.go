@@ -0,0 +1,100 @@
+package aphfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PostgresRenderer renders filters to postgresql conditions with numbered
+// placeholders($1, $2 ...) for the bind values
+type PostgresRenderer struct {
+	// Mapping between filter fields and table columns
+	Columns map[string]string
+	args    []interface{}
+}
+
+// NewPostgresRenderer is the constructor for PostgresRenderer
+func NewPostgresRenderer(columns map[string]string) *PostgresRenderer {
+	return &PostgresRenderer{Columns: columns}
+}
+
+// Args returns the bind values of all the rendered conditions
+func (r *PostgresRenderer) Args() []interface{} {
+	return r.args
+}
+
+// Condition generates the postgresql condition of the filter
+func (r *PostgresRenderer) Condition(f *Filter) (string, error) {
+	col, err := lookupField(r.Columns, f.Field)
+	if err != nil {
+		return "", err
+	}
+	switch f.Operator {
+	case Null:
+		if f.Value == "true" {
+			return fmt.Sprintf("%s IS NULL", col), nil
+		}
+		return fmt.Sprintf("%s IS NOT NULL", col), nil
+	case In, NotIn:
+		ph := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			ph[i] = r.bind(TypedValue(f, v))
+		}
+		op := "IN"
+		if f.Operator == NotIn {
+			op = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s (%s)", col, op, strings.Join(ph, ", ")), nil
+	case Contains, NotContains:
+		op := "~*"
+		if f.Operator == NotContains {
+			op = "!~*"
+		}
+		return fmt.Sprintf(
+			"%s %s %s", col, op,
+			r.bind(fmt.Sprintf(".*%s.*", regexp.QuoteMeta(f.Value))),
+		), nil
+	case Match, NotMatch:
+		op := "~"
+		if f.Operator == NotMatch {
+			op = "!~"
+		}
+		return fmt.Sprintf("%s %s %s", col, op, r.bind(f.Value)), nil
+	case Equal, NotEqual, StrictEqual, StrictNotEqual:
+		op := "="
+		if f.Operator == NotEqual || f.Operator == StrictNotEqual {
+			op = "!="
+		}
+		return fmt.Sprintf("%s %s %s", col, op, r.bind(f.Value)), nil
+	case Greater, GreaterOrEqual, Less, LessOrEqual:
+		return fmt.Sprintf(
+			"%s %s %s", col, f.Operator,
+			r.bind(TypedValue(f, f.Value)),
+		), nil
+	case DateEqual, DateGreater, DateGreaterOrEqual, DateLess, DateLessOrEqual:
+		d, err := ParseDate(f.Value)
+		if err != nil {
+			return "", err
+		}
+		op := strings.TrimPrefix(string(f.Operator), "$")
+		if f.Operator == DateEqual {
+			op = "="
+		}
+		return fmt.Sprintf("%s %s %s", col, op, r.bind(d)), nil
+	}
+	return "", fmt.Errorf("filter operator %s is not supported", f.Operator)
+}
+
+func (r *PostgresRenderer) bind(v interface{}) string {
+	r.args = append(r.args, v)
+	return fmt.Sprintf("$%d", len(r.args))
+}
+
+// ToPostgres generates the postgresql condition along with its bind values
+// from the expression
+func ToPostgres(columns map[string]string, expr *Expr) (string, []interface{}, error) {
+	r := NewPostgresRenderer(columns)
+	clause, err := Render(expr, r)
+	return clause, r.Args(), err
+}
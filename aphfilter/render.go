@@ -0,0 +1,62 @@
+package aphfilter
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Renderer generates the storage specific condition of a single filter. The
+// renderers are stateful, they collect the bind values of every rendered
+// filter, so a new one has to be used for every expression.
+type Renderer interface {
+	Condition(f *Filter) (string, error)
+}
+
+// Render generates the condition of the entire expression tree using the
+// renderer. The grouping of the expression is kept by parenthesizing every
+// nested group. The condition of an empty expression is empty.
+func Render(expr *Expr, r Renderer) (string, error) {
+	var clause bytes.Buffer
+	if err := render(&clause, expr, r, false); err != nil {
+		return "", err
+	}
+	return clause.String(), nil
+}
+
+func render(clause *bytes.Buffer, expr *Expr, r Renderer, nested bool) error {
+	if expr.IsEmpty() {
+		return nil
+	}
+	if expr.IsLeaf() {
+		cond, err := r.Condition(expr.Filter)
+		if err != nil {
+			return err
+		}
+		clause.WriteString(cond)
+		return nil
+	}
+	if nested {
+		clause.WriteString("(")
+	}
+	for i, c := range expr.Children {
+		if i > 0 {
+			clause.WriteString(fmt.Sprintf(" %s ", expr.Logic))
+		}
+		if err := render(clause, c, r, true); err != nil {
+			return err
+		}
+	}
+	if nested {
+		clause.WriteString(")")
+	}
+	return nil
+}
+
+// lookupField maps the filter field to the storage field
+func lookupField(fmap map[string]string, field string) (string, error) {
+	col, ok := fmap[field]
+	if !ok {
+		return "", fmt.Errorf("no mapping found for filter field %s", field)
+	}
+	return col, nil
+}
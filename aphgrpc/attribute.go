@@ -0,0 +1,213 @@
+package aphgrpc
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dictyBase/apihelpers/aphcollection"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// AttributeRule is a validation rule for the value of an attribute, the zero
+// value of any of its fields skips that check
+type AttributeRule struct {
+	// MinLength is the minimum length of a string or the minimum number of
+	// elements of a list
+	MinLength int
+	// MaxLength is the maximum length of a string or the maximum number of
+	// elements of a list
+	MaxLength int
+	// Pattern has to match every string value
+	Pattern *regexp.Regexp
+	// Enum is the list of allowed values, the name is used for the values of
+	// an enum field
+	Enum []string
+}
+
+// AttributeRulesInfo is implemented by services that validate the attribute
+// values of create and update requests
+type AttributeRulesInfo interface {
+	AttributeRules() map[string]*AttributeRule
+}
+
+// AttributeRulesOption sets the validation rules of the attributes
+func AttributeRulesOption(rules map[string]*AttributeRule) Option {
+	return func(so *ServiceOptions) {
+		so.AttrRules = rules
+	}
+}
+
+// AttributeRules returns the validation rules of the attributes
+func (s *Service) AttributeRules() map[string]*AttributeRule {
+	return s.AttrRules
+}
+
+// ValidateCreate validates the attributes of a create request message
+// against the required attributes and the rules of the service. It returns
+// an InvalidArgument error with a field violation for every failing
+// attribute.
+func (s *Service) ValidateCreate(ctx context.Context, m proto.Message) error {
+	return validationError(ctx, ValidateAttributes(m, s.RequiredAttrs(), s.AttrRules))
+}
+
+// ValidateUpdate validates the attributes of an update request message
+// against the rules of the service. The attributes that are absent in the
+// message are left unchanged by the update, so they are not required.
+func (s *Service) ValidateUpdate(ctx context.Context, m proto.Message) error {
+	return validationError(ctx, ValidateAttributes(m, nil, s.AttrRules))
+}
+
+// validateRequestAttributes validates the attributes of the create and
+// update requests, which are recognized from the prefix of the method name
+func validateRequestAttributes(ctx context.Context, jsapi JSONAPIParamsInfo, method string, req interface{}) error {
+	m, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+	var rules map[string]*AttributeRule
+	if ri, ok := jsapi.(AttributeRulesInfo); ok {
+		rules = ri.AttributeRules()
+	}
+	name := method[strings.LastIndex(method, "/")+1:]
+	switch {
+	case strings.HasPrefix(name, "Create"):
+		return validationError(ctx, ValidateAttributes(m, jsapi.RequiredAttrs(), rules))
+	case strings.HasPrefix(name, "Update"):
+		return validationError(ctx, ValidateAttributes(m, nil, rules))
+	}
+	return nil
+}
+
+func validationError(ctx context.Context, violations []*errdetails.BadRequest_FieldViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	var attrs []string
+	for _, v := range violations {
+		attrs = append(attrs, strings.TrimPrefix(v.Field, "data.attributes."))
+	}
+	return HandleFieldViolationError(
+		ctx,
+		fmt.Errorf("invalid attributes %s", strings.Join(attrs, ",")),
+		violations...,
+	)
+}
+
+// ValidateAttributes validates the attributes of a request message using
+// proto reflection. The attributes are looked up in the data.attributes
+// message of the JSON API request, falling back to the message itself. The
+// attributes are matched with either the proto or the JSON name of the
+// fields. A field violation is returned for every required attribute that is
+// absent and every attribute that fails its rule.
+//
+// The fields with explicit presence, such as the messages and the optional
+// scalars, are present when they are set, even to their zero values, and are
+// validated only then. The other scalars are absent for the required check
+// when they have their zero values, which are still validated by the rules,
+// for example a MinLength of 1 rejects an empty string. A list or a map is
+// present if it has any element.
+func ValidateAttributes(m proto.Message, required []string, rules map[string]*AttributeRule) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	attrs := attributesMessage(proto.MessageReflect(m))
+	for _, name := range required {
+		fd := lookupAttribute(attrs, name)
+		if fd == nil || !attrs.Has(fd) {
+			violations = append(violations, AttributeViolation(name, "attribute is required"))
+		}
+	}
+	var names []string
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fd := lookupAttribute(attrs, name)
+		if fd == nil || (fd.HasPresence() && !attrs.Has(fd)) {
+			continue
+		}
+		if err := rules[name].validate(fd, attrs.Get(fd)); err != nil {
+			violations = append(violations, AttributeViolation(name, err.Error()))
+		}
+	}
+	return violations
+}
+
+// attributesMessage returns the data.attributes message of a JSON API
+// request or the message itself in its absence
+func attributesMessage(m protoreflect.Message) protoreflect.Message {
+	for _, name := range []protoreflect.Name{"data", "attributes"} {
+		fd := m.Descriptor().Fields().ByName(name)
+		if fd == nil || fd.Message() == nil || fd.IsList() || fd.IsMap() || !m.Has(fd) {
+			continue
+		}
+		m = m.Get(fd).Message()
+	}
+	return m
+}
+
+func lookupAttribute(m protoreflect.Message, name string) protoreflect.FieldDescriptor {
+	fields := m.Descriptor().Fields()
+	if fd := fields.ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return fields.ByJSONName(name)
+}
+
+// validate checks the value of the field against the rule
+func (r *AttributeRule) validate(fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	if fd.IsList() {
+		l := v.List()
+		if err := r.validateLength(l.Len(), "elements"); err != nil {
+			return err
+		}
+		for i := 0; i < l.Len(); i++ {
+			if err := r.validateScalar(fd, l.Get(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if fd.Kind() == protoreflect.StringKind {
+		if err := r.validateLength(utf8.RuneCountInString(v.String()), "characters"); err != nil {
+			return err
+		}
+	}
+	return r.validateScalar(fd, v)
+}
+
+func (r *AttributeRule) validateLength(n int, unit string) error {
+	if r.MinLength > 0 && n < r.MinLength {
+		return fmt.Errorf("should have at least %d %s", r.MinLength, unit)
+	}
+	if r.MaxLength > 0 && n > r.MaxLength {
+		return fmt.Errorf("should have at most %d %s", r.MaxLength, unit)
+	}
+	return nil
+}
+
+func (r *AttributeRule) validateScalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	var s string
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind, protoreflect.BytesKind:
+		return nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			s = string(ev.Name())
+		}
+	default:
+		s = fmt.Sprint(v.Interface())
+	}
+	if r.Pattern != nil && fd.Kind() == protoreflect.StringKind && !r.Pattern.MatchString(s) {
+		return fmt.Errorf("should match the pattern %s", r.Pattern)
+	}
+	if len(r.Enum) > 0 && !aphcollection.Contains(r.Enum, s) {
+		return fmt.Errorf("should be one of %s", strings.Join(r.Enum, ","))
+	}
+	return nil
+}
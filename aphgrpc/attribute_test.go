@@ -0,0 +1,74 @@
+package aphgrpc
+
+import (
+	"regexp"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func violationFields(violations []*errdetails.BadRequest_FieldViolation) map[string]bool {
+	fields := make(map[string]bool)
+	for _, v := range violations {
+		fields[v.Field] = true
+	}
+	return fields
+}
+
+func TestValidateAttributesRequired(t *testing.T) {
+	required := []string{"name", "count", "updatedAt", "label", "tags", "level"}
+	req := testRequest(t, func(attrs protoreflect.Message) {
+		setField(attrs, "label", stringValue(""))
+		setField(attrs, "level", protoreflect.ValueOfInt32(2))
+	})
+	fields := violationFields(ValidateAttributes(req, required, nil))
+	// the zero values of the scalars without presence are absent
+	for _, f := range []string{"data.attributes.name", "data.attributes.count", "data.attributes.updatedAt", "data.attributes.tags"} {
+		if !fields[f] {
+			t.Fatalf("expected violation of %s, got %v", f, fields)
+		}
+	}
+	if len(fields) != 4 {
+		t.Fatalf("expected four violations, got %v", fields)
+	}
+	// the explicit zero values satisfy the required check
+	req = testRequest(t, func(attrs protoreflect.Message) {
+		setField(attrs, "name", protoreflect.ValueOfString("sadA"))
+		setField(attrs, "count", protoreflect.ValueOfInt64(0))
+	})
+	fields = violationFields(ValidateAttributes(req, []string{"name", "count"}, nil))
+	if len(fields) != 0 {
+		t.Fatalf("unexpected violations %v", fields)
+	}
+}
+
+func TestValidateAttributesRules(t *testing.T) {
+	rules := map[string]*AttributeRule{
+		"name":  {MinLength: 1},
+		"count": {Enum: []string{"1", "2"}},
+		"tags":  {MinLength: 1, Pattern: regexp.MustCompile("^[a-z]+$")},
+		"level": {Enum: []string{"1", "2"}},
+	}
+	fields := violationFields(ValidateAttributes(testRequest(t, nil), nil, rules))
+	// the zero values of the scalars without presence and the empty list
+	// are validated, whereas the unset optional count is skipped
+	for _, f := range []string{"data.attributes.name", "data.attributes.tags", "data.attributes.level"} {
+		if !fields[f] {
+			t.Fatalf("expected violation of %s, got %v", f, fields)
+		}
+	}
+	if fields["data.attributes.count"] {
+		t.Fatal("unexpected violation of the unset optional attribute")
+	}
+	req := testRequest(t, func(attrs protoreflect.Message) {
+		setField(attrs, "name", protoreflect.ValueOfString("sadA"))
+		setField(attrs, "count", protoreflect.ValueOfInt64(0))
+		setField(attrs, "level", protoreflect.ValueOfInt32(2))
+		setTags(attrs, "axenic", "Null")
+	})
+	fields = violationFields(ValidateAttributes(req, nil, rules))
+	if len(fields) != 2 || !fields["data.attributes.count"] || !fields["data.attributes.tags"] {
+		t.Fatalf("expected violations of count and tags, got %v", fields)
+	}
+}
@@ -0,0 +1,348 @@
+package aphgrpc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/protobuf/field_mask"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/mgutz/dat.v2/dat"
+)
+
+// UpdateMaskField is the name of the field mask of an update request
+const UpdateMaskField = "update_mask"
+
+// Change is a single changed attribute of a partial update
+type Change struct {
+	// Field is the proto name of the attribute
+	Field string
+	// Value is the new value of the attribute, nil clears it. The
+	// wrapper types are unwrapped, google.protobuf.Timestamp and
+	// google.protobuf.Duration are converted to time.Time and time.Duration,
+	// the enums are converted to their numbers and the lists to
+	// []interface{}, the other messages are kept as they are and could not
+	// be stored in a column.
+	Value interface{}
+}
+
+// Changeset is the typed set of changes of a JSON API PATCH request
+type Changeset struct {
+	Changes []*Change
+	attrs   protoreflect.Message
+	fields  []protoreflect.FieldDescriptor
+}
+
+// NewChangeset creates the changeset from the attributes of a request
+// message, which are looked up in its data.attributes message falling back
+// to the message itself. The paths of the mask, relative to the attributes,
+// are the changed attributes whether they are set or not, so that they
+// could be updated to their zero values. The update_mask field of the
+// message is used in the absence of the mask. Without any mask, the
+// attributes that are present in the message are the changed ones, which
+// for the scalars without explicit presence excludes their zero values.
+func NewChangeset(m proto.Message, mask *field_mask.FieldMask) (*Changeset, error) {
+	pm := proto.MessageReflect(m)
+	if !pm.IsValid() {
+		return nil, fmt.Errorf("unable to create changeset from an empty message")
+	}
+	attrs := attributesMessage(pm)
+	cs := &Changeset{attrs: attrs}
+	paths := mask.GetPaths()
+	if mask == nil {
+		paths = updateMaskPaths(pm)
+	}
+	if len(paths) > 0 {
+		for _, p := range paths {
+			if strings.Contains(p, ".") {
+				return nil, fmt.Errorf("nested path %s of update mask is not supported", p)
+			}
+			fd := lookupAttribute(attrs, p)
+			if fd == nil {
+				return nil, fmt.Errorf("path %s of update mask is not an attribute", p)
+			}
+			cs.add(fd)
+		}
+		return cs, nil
+	}
+	fields := attrs.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); attrs.Has(fd) {
+			cs.add(fd)
+		}
+	}
+	return cs, nil
+}
+
+// updateMaskPaths returns the paths of the update_mask field of the message
+func updateMaskPaths(m protoreflect.Message) []string {
+	fd := m.Descriptor().Fields().ByName(UpdateMaskField)
+	if fd == nil || fd.Message() == nil || fd.IsList() || !m.Has(fd) {
+		return nil
+	}
+	mask := m.Get(fd).Message()
+	pfd := mask.Descriptor().Fields().ByName("paths")
+	if pfd == nil || !pfd.IsList() || pfd.Kind() != protoreflect.StringKind {
+		return nil
+	}
+	var paths []string
+	l := mask.Get(pfd).List()
+	for i := 0; i < l.Len(); i++ {
+		paths = append(paths, l.Get(i).String())
+	}
+	return paths
+}
+
+func (cs *Changeset) add(fd protoreflect.FieldDescriptor) {
+	var v interface{}
+	if cs.attrs.Has(fd) || (!fd.HasPresence() && !fd.IsList() && !fd.IsMap()) {
+		v = fieldValue(fd, cs.attrs.Get(fd))
+	}
+	cs.Changes = append(cs.Changes, &Change{Field: string(fd.Name()), Value: v})
+	cs.fields = append(cs.fields, fd)
+}
+
+// IsEmpty reports whether the changeset has no change
+func (cs *Changeset) IsEmpty() bool {
+	return len(cs.Changes) == 0
+}
+
+// Fields returns the changed attributes, they are meant to be the fields of
+// the update event
+func (cs *Changeset) Fields() []string {
+	var fields []string
+	for _, c := range cs.Changes {
+		fields = append(fields, c.Field)
+	}
+	return fields
+}
+
+// Value returns the new value of an attribute, it is false if the attribute
+// is not changed
+func (cs *Changeset) Value(field string) (interface{}, bool) {
+	for _, c := range cs.Changes {
+		if c.Field == field {
+			return c.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Columns maps the changes to the storage columns, the attributes without
+// any mapping are used as the columns. It fails for a change whose value is
+// a message other than the well known types.
+func (cs *Changeset) Columns(mapping map[string]string) (map[string]interface{}, error) {
+	columns := make(map[string]interface{})
+	for _, c := range cs.Changes {
+		if hasMessage(c.Value) {
+			return nil, fmt.Errorf("attribute %s is a message that could not be stored in a column", c.Field)
+		}
+		col, ok := mapping[c.Field]
+		if !ok {
+			col = c.Field
+		}
+		columns[col] = c.Value
+	}
+	return columns, nil
+}
+
+// hasMessage reports whether the value is or contains a message
+func hasMessage(v interface{}) bool {
+	switch t := v.(type) {
+	case protoreflect.ProtoMessage:
+		return true
+	case []interface{}:
+		for _, e := range t {
+			if hasMessage(e) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, e := range t {
+			if hasMessage(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DatUpdate sets the changes on the dat update builder, see Columns
+func (cs *Changeset) DatUpdate(b *dat.UpdateBuilder, mapping map[string]string) (*dat.UpdateBuilder, error) {
+	columns, err := cs.Columns(mapping)
+	if err != nil {
+		return b, err
+	}
+	return b.SetMap(columns), nil
+}
+
+// AQLUpdate generates the AQL UPDATE statement of the changes for the
+// document with the given key along with its bind parameters. The statement
+// returns the updated document. It fails for a change whose value is a
+// message other than the well known types, as it has no json form in the
+// bind parameters.
+func (cs *Changeset) AQLUpdate(collection, key string, mapping map[string]string) (string, map[string]interface{}, error) {
+	bindVars := map[string]interface{}{
+		"@collection": collection,
+		"key":         key,
+	}
+	var attrs []string
+	for i, c := range cs.Changes {
+		if hasMessage(c.Value) {
+			return "", nil, fmt.Errorf("attribute %s is a message that could not be stored in a document", c.Field)
+		}
+		attr, ok := mapping[c.Field]
+		if !ok {
+			attr = c.Field
+		}
+		bv := fmt.Sprintf("change%d", i)
+		bindVars[bv] = aqlValue(c.Value)
+		attrs = append(attrs, fmt.Sprintf("%q: @%s", attr, bv))
+	}
+	return fmt.Sprintf(
+		"UPDATE @key WITH { %s } IN @@collection RETURN NEW",
+		strings.Join(attrs, ", "),
+	), bindVars, nil
+}
+
+// aqlValue converts the value to a type that is supported by the json
+// encoding of the bind parameters
+func aqlValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	case time.Duration:
+		return t.String()
+	}
+	return v
+}
+
+// Apply merges the changes into the attributes of the destination message,
+// the attributes are matched by their proto names and have to be of the same
+// type in both the messages. The unset attributes of the changeset are
+// cleared in the destination.
+func (cs *Changeset) Apply(dst proto.Message) error {
+	dattrs := attributesMessage(proto.MessageReflect(dst))
+	for _, fd := range cs.fields {
+		dfd := dattrs.Descriptor().Fields().ByName(fd.Name())
+		if dfd == nil {
+			return fmt.Errorf("attribute %s is absent in %s", fd.Name(), dattrs.Descriptor().FullName())
+		}
+		if !sameType(fd, dfd) {
+			return fmt.Errorf("attribute %s is of different type in %s", fd.Name(), dattrs.Descriptor().FullName())
+		}
+		if !cs.attrs.Has(fd) {
+			dattrs.Clear(dfd)
+			continue
+		}
+		dattrs.Set(dfd, copyValue(dattrs, dfd, cs.attrs.Get(fd)))
+	}
+	return nil
+}
+
+func sameType(a, b protoreflect.FieldDescriptor) bool {
+	if a.Kind() != b.Kind() || a.IsList() != b.IsList() || a.IsMap() != b.IsMap() {
+		return false
+	}
+	switch {
+	case a.IsMap():
+		return sameType(a.MapKey(), b.MapKey()) && sameType(a.MapValue(), b.MapValue())
+	case a.Message() != nil:
+		return a.Message().FullName() == b.Message().FullName()
+	case a.Enum() != nil:
+		return a.Enum().FullName() == b.Enum().FullName()
+	}
+	return true
+}
+
+// copyValue deep copies the value of the field for the destination message
+func copyValue(dst protoreflect.Message, fd protoreflect.FieldDescriptor, v protoreflect.Value) protoreflect.Value {
+	switch {
+	case fd.IsList():
+		nl := dst.NewField(fd).List()
+		l := v.List()
+		for i := 0; i < l.Len(); i++ {
+			nl.Append(cloneScalar(fd, l.Get(i)))
+		}
+		return protoreflect.ValueOfList(nl)
+	case fd.IsMap():
+		nm := dst.NewField(fd).Map()
+		v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+			nm.Set(k, cloneScalar(fd.MapValue(), mv))
+			return true
+		})
+		return protoreflect.ValueOfMap(nm)
+	}
+	return cloneScalar(fd, v)
+}
+
+func cloneScalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return protoreflect.ValueOfMessage(protov2.Clone(v.Message().Interface()).ProtoReflect())
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes(append([]byte{}, v.Bytes()...))
+	}
+	return v
+}
+
+// fieldValue converts the value of the field to its go value
+func fieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch {
+	case fd.IsList():
+		var values []interface{}
+		l := v.List()
+		for i := 0; i < l.Len(); i++ {
+			values = append(values, scalarValue(fd, l.Get(i)))
+		}
+		return values
+	case fd.IsMap():
+		values := make(map[string]interface{})
+		v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+			values[k.String()] = scalarValue(fd.MapValue(), mv)
+			return true
+		})
+		return values
+	}
+	return scalarValue(fd, v)
+}
+
+func scalarValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		return int32(v.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageValue(v.Message())
+	}
+	return v.Interface()
+}
+
+// messageValue unwraps the well known types
+func messageValue(m protoreflect.Message) interface{} {
+	fields := m.Descriptor().Fields()
+	switch m.Descriptor().FullName() {
+	case "google.protobuf.Timestamp":
+		return time.Unix(
+			m.Get(fields.ByName("seconds")).Int(),
+			m.Get(fields.ByName("nanos")).Int(),
+		).UTC()
+	case "google.protobuf.Duration":
+		return time.Duration(m.Get(fields.ByName("seconds")).Int())*time.Second +
+			time.Duration(m.Get(fields.ByName("nanos")).Int())
+	case "google.protobuf.StringValue", "google.protobuf.BytesValue",
+		"google.protobuf.BoolValue", "google.protobuf.Int32Value",
+		"google.protobuf.Int64Value", "google.protobuf.UInt32Value",
+		"google.protobuf.UInt64Value", "google.protobuf.FloatValue",
+		"google.protobuf.DoubleValue":
+		return m.Get(fields.ByName("value")).Interface()
+	}
+	return m.Interface()
+}
+
+// ChangesetUpdate creates the dat update builder of the table with the
+// changes mapped to the columns of the sparse fieldsets
+func (s *Service) ChangesetUpdate(table string, cs *Changeset) (*dat.UpdateBuilder, error) {
+	return cs.DatUpdate(s.Dbh.Update(table), s.FieldsToColumns)
+}
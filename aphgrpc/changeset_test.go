@@ -0,0 +1,185 @@
+package aphgrpc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/mgutz/dat.v2/dat"
+)
+
+func TestChangesetPresence(t *testing.T) {
+	tm := time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC)
+	req := testRequest(t, func(attrs protoreflect.Message) {
+		setField(attrs, "count", protoreflect.ValueOfInt64(0))
+		setField(attrs, "updated_at", timestampValue(tm))
+		setField(attrs, "label", stringValue("axenic"))
+		setTags(attrs, "a", "b")
+	})
+	cs, err := NewChangeset(req, nil)
+	if err != nil {
+		t.Fatalf("error in creating changeset %s", err)
+	}
+	if fields := cs.Fields(); !reflect.DeepEqual(fields, []string{"count", "updated_at", "label", "tags"}) {
+		t.Fatalf("unexpected changed attributes %v", fields)
+	}
+	expected := map[string]interface{}{
+		"count":      int64(0),
+		"updated_at": tm,
+		"label":      "axenic",
+		"tags":       []interface{}{"a", "b"},
+	}
+	for f, ev := range expected {
+		v, ok := cs.Value(f)
+		if !ok || !reflect.DeepEqual(v, ev) {
+			t.Fatalf("expected value %#v of %s, got %#v", ev, f, v)
+		}
+	}
+	if _, ok := cs.Value("name"); ok {
+		t.Fatal("expected unset name to be absent from the changeset")
+	}
+}
+
+func TestChangesetMask(t *testing.T) {
+	req := testRequest(t, func(attrs protoreflect.Message) {
+		setField(attrs, "label", stringValue("axenic"))
+	})
+	mask := &field_mask.FieldMask{Paths: []string{"name", "active", "level", "count", "label"}}
+	cs, err := NewChangeset(req, mask)
+	if err != nil {
+		t.Fatalf("error in creating changeset %s", err)
+	}
+	expected := []*Change{
+		{Field: "name", Value: ""},
+		{Field: "active", Value: false},
+		{Field: "level", Value: int32(0)},
+		{Field: "count", Value: nil},
+		{Field: "label", Value: "axenic"},
+	}
+	if !reflect.DeepEqual(cs.Changes, expected) {
+		t.Fatalf("unexpected changes %v", cs.Fields())
+	}
+	for _, p := range []string{"parent.name", "color"} {
+		if _, err := NewChangeset(req, &field_mask.FieldMask{Paths: []string{p}}); err == nil {
+			t.Fatalf("expected error for update mask path %s", p)
+		}
+	}
+}
+
+func TestChangesetUpdateMaskField(t *testing.T) {
+	req := testRequest(t, nil)
+	mask := (&field_mask.FieldMask{Paths: []string{"active"}}).ProtoReflect()
+	setField(req, UpdateMaskField, protoreflect.ValueOfMessage(mask))
+	cs, err := NewChangeset(req, nil)
+	if err != nil {
+		t.Fatalf("error in creating changeset %s", err)
+	}
+	if fields := cs.Fields(); !reflect.DeepEqual(fields, []string{"active"}) {
+		t.Fatalf("unexpected changed attributes %v", fields)
+	}
+}
+
+func TestChangesetApply(t *testing.T) {
+	tm := time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC)
+	req := testRequest(t, func(attrs protoreflect.Message) {
+		setField(attrs, "label", stringValue("axenic"))
+		setTags(attrs, "a")
+	})
+	cs, err := NewChangeset(req, &field_mask.FieldMask{Paths: []string{"name", "updated_at", "label", "tags"}})
+	if err != nil {
+		t.Fatalf("error in creating changeset %s", err)
+	}
+	dst := testRequest(t, func(attrs protoreflect.Message) {
+		setField(attrs, "name", protoreflect.ValueOfString("sadA"))
+		setField(attrs, "updated_at", timestampValue(tm))
+		setField(attrs, "level", protoreflect.ValueOfInt32(3))
+		setTags(attrs, "x", "y")
+	})
+	if err := cs.Apply(dst); err != nil {
+		t.Fatalf("error in applying changeset %s", err)
+	}
+	attrs := attributesMessage(dst)
+	fields := attrs.Descriptor().Fields()
+	for _, f := range []string{"name", "updated_at"} {
+		if attrs.Has(fields.ByName(protoreflect.Name(f))) {
+			t.Fatalf("expected unset attribute %s to be cleared", f)
+		}
+	}
+	if v := fieldValue(fields.ByName("label"), attrs.Get(fields.ByName("label"))); v != "axenic" {
+		t.Fatalf("expected label axenic, got %#v", v)
+	}
+	if v := fieldValue(fields.ByName("tags"), attrs.Get(fields.ByName("tags"))); !reflect.DeepEqual(v, []interface{}{"a"}) {
+		t.Fatalf("expected tags [a], got %#v", v)
+	}
+	if v := attrs.Get(fields.ByName("level")).Int(); v != 3 {
+		t.Fatalf("expected unchanged level 3, got %d", v)
+	}
+}
+
+func TestChangesetAQLUpdate(t *testing.T) {
+	tm := time.Date(2019, 3, 4, 5, 6, 7, 8, time.UTC)
+	req := testRequest(t, func(attrs protoreflect.Message) {
+		setField(attrs, "name", protoreflect.ValueOfString("sadA"))
+		setField(attrs, "updated_at", timestampValue(tm))
+	})
+	cs, err := NewChangeset(req, nil)
+	if err != nil {
+		t.Fatalf("error in creating changeset %s", err)
+	}
+	stmt, bindVars, err := cs.AQLUpdate("strain", "1", map[string]string{"name": "strain_name"})
+	if err != nil {
+		t.Fatalf("error in generating update %s", err)
+	}
+	exstmt := `UPDATE @key WITH { "strain_name": @change0, "updated_at": @change1 } IN @@collection RETURN NEW`
+	if stmt != exstmt {
+		t.Fatalf("expected statement %s, got %s", exstmt, stmt)
+	}
+	expected := map[string]interface{}{
+		"@collection": "strain",
+		"key":         "1",
+		"change0":     "sadA",
+		"change1":     "2019-03-04T05:06:07.000000008Z",
+	}
+	if !reflect.DeepEqual(bindVars, expected) {
+		t.Fatalf("expected bind parameters %v, got %v", expected, bindVars)
+	}
+}
+
+func TestChangesetColumns(t *testing.T) {
+	req := testRequest(t, func(attrs protoreflect.Message) {
+		setField(attrs, "name", protoreflect.ValueOfString("sadA"))
+		setField(attrs, "level", protoreflect.ValueOfInt32(2))
+	})
+	cs, err := NewChangeset(req, nil)
+	if err != nil {
+		t.Fatalf("error in creating changeset %s", err)
+	}
+	columns, err := cs.Columns(map[string]string{"name": "strain_name"})
+	if err != nil {
+		t.Fatalf("error in mapping columns %s", err)
+	}
+	expected := map[string]interface{}{"strain_name": "sadA", "level": int32(2)}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Fatalf("expected columns %v, got %v", expected, columns)
+	}
+	req = testRequest(t, func(attrs protoreflect.Message) {
+		parent := attrs.NewField(attrs.Descriptor().Fields().ByName("parent")).Message()
+		setField(parent, "name", protoreflect.ValueOfString("AX4"))
+		setField(attrs, "parent", protoreflect.ValueOfMessage(parent))
+	})
+	cs, err = NewChangeset(req, nil)
+	if err != nil {
+		t.Fatalf("error in creating changeset %s", err)
+	}
+	if _, err := cs.Columns(nil); err == nil {
+		t.Fatal("expected error for message attribute")
+	}
+	if _, err := cs.DatUpdate(&dat.UpdateBuilder{}, nil); err == nil {
+		t.Fatal("expected error for message attribute in update")
+	}
+	if _, _, err := cs.AQLUpdate("strain", "1", nil); err == nil {
+		t.Fatal("expected error for message attribute in AQL update")
+	}
+}
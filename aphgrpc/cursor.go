@@ -0,0 +1,310 @@
+package aphgrpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dictyBase/apihelpers/aphlink"
+	"github.com/dictyBase/go-genproto/dictybaseapis/api/jsonapi"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys of the page[after] and page[before] query parameters, the
+// jsonapi request messages have no cursor field so they are passed on by the
+// gateway through CursorMetadata
+const (
+	CursorAfterKey  = "jsonapi-page-after"
+	CursorBeforeKey = "jsonapi-page-before"
+)
+
+// CursorDirection denotes the direction of traversal from a cursor
+type CursorDirection int
+
+const (
+	// CursorAfter fetches the records that come after the cursor
+	CursorAfter CursorDirection = iota
+	// CursorBefore fetches the records that come before the cursor
+	CursorBefore
+)
+
+// Cursor is the position of a record in a keyset paginated collection. It is
+// handed to the client only in its opaque encoded form.
+type Cursor struct {
+	// Value of the sort key of the record, it is decoded as a time.Time, an
+	// int64, a float64, a bool or a string depending on its encoded type
+	Value interface{}
+	// ID of the record, breaks the tie between identical sort keys, it is
+	// decoded the same way as the value
+	ID interface{}
+}
+
+// Types of the encoded cursor values
+const (
+	cursorString = "s"
+	cursorInt    = "i"
+	cursorFloat  = "f"
+	cursorBool   = "b"
+	cursorTime   = "t"
+)
+
+// cursorValue is the encoded form of a typed cursor value, the times are
+// kept in RFC3339 format with nanoseconds and the numbers are kept numeric
+type cursorValue struct {
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v"`
+}
+
+// encodedCursor is the json form of a cursor
+type encodedCursor struct {
+	Value *cursorValue `json:"v"`
+	ID    *cursorValue `json:"i"`
+}
+
+// NewCursor creates a cursor from the sort key value and id of a record
+func NewCursor(value, id interface{}) *Cursor {
+	return &Cursor{Value: value, ID: id}
+}
+
+// EncodeCursor encodes the cursor to an opaque url safe string. The values of
+// the cursor are converted to one of the supported types, any unsupported
+// type is kept in its string form.
+func EncodeCursor(c *Cursor) string {
+	b, _ := json.Marshal(&encodedCursor{
+		Value: encodeCursorValue(c.Value),
+		ID:    encodeCursorValue(c.ID),
+	})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor decodes an opaque string created by EncodeCursor
+func DecodeCursor(s string) (*Cursor, error) {
+	c := &Cursor{}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("unable to decode cursor %s", err)
+	}
+	ec := &encodedCursor{}
+	if err := json.Unmarshal(b, ec); err != nil {
+		return c, fmt.Errorf("malformed cursor %s", err)
+	}
+	if ec.ID == nil {
+		return c, fmt.Errorf("cursor is missing the record id")
+	}
+	if ec.Value == nil {
+		return c, fmt.Errorf("cursor is missing the sort key value")
+	}
+	if c.Value, err = decodeCursorValue(ec.Value); err != nil {
+		return c, err
+	}
+	if c.ID, err = decodeCursorValue(ec.ID); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+func encodeCursorValue(v interface{}) *cursorValue {
+	var typ string
+	switch t := v.(type) {
+	case time.Time:
+		typ, v = cursorTime, t.Format(time.RFC3339Nano)
+	case int:
+		typ, v = cursorInt, int64(t)
+	case int32:
+		typ, v = cursorInt, int64(t)
+	case int64:
+		typ = cursorInt
+	case uint32:
+		typ, v = cursorInt, int64(t)
+	case float32:
+		typ, v = cursorFloat, float64(t)
+	case float64:
+		typ = cursorFloat
+	case bool:
+		typ = cursorBool
+	case string:
+		typ = cursorString
+	default:
+		typ, v = cursorString, fmt.Sprintf("%v", v)
+	}
+	b, _ := json.Marshal(v)
+	return &cursorValue{Type: typ, Value: b}
+}
+
+func decodeCursorValue(cv *cursorValue) (interface{}, error) {
+	var err error
+	var v interface{}
+	switch cv.Type {
+	case cursorTime:
+		var s string
+		if err = json.Unmarshal(cv.Value, &s); err == nil {
+			v, err = time.Parse(time.RFC3339Nano, s)
+		}
+	case cursorInt:
+		var i int64
+		err = json.Unmarshal(cv.Value, &i)
+		v = i
+	case cursorFloat:
+		var f float64
+		err = json.Unmarshal(cv.Value, &f)
+		v = f
+	case cursorBool:
+		var b bool
+		err = json.Unmarshal(cv.Value, &b)
+		v = b
+	case cursorString:
+		var s string
+		err = json.Unmarshal(cv.Value, &s)
+		v = s
+	default:
+		return nil, fmt.Errorf("unknown cursor value type %s", cv.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor value %s", err)
+	}
+	return v, nil
+}
+
+// CursorMetadata is a metadata annotator of the grpc gateway, registered with
+// runtime.WithMetadata, that passes on the page[after] and page[before] query
+// parameters of the HTTP request to the gRPC handlers
+func CursorMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	q := r.URL.Query()
+	if v := q.Get("page[after]"); len(v) > 0 {
+		md.Set(CursorAfterKey, v)
+	}
+	if v := q.Get("page[before]"); len(v) > 0 {
+		md.Set(CursorBeforeKey, v)
+	}
+	if len(md) == 0 {
+		return nil
+	}
+	return md
+}
+
+// CursorFromContext returns the decoded cursor that is passed on by the
+// gateway along with its direction of traversal, the cursor is nil for the
+// first page. It fails for a malformed cursor or when both page[after] and
+// page[before] are given.
+func CursorFromContext(ctx context.Context) (*Cursor, CursorDirection, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, CursorAfter, nil
+	}
+	after, before := md.Get(CursorAfterKey), md.Get(CursorBeforeKey)
+	switch {
+	case len(after) > 0 && len(before) > 0:
+		return nil, CursorAfter, fmt.Errorf("page[after] and page[before] cannot be used together")
+	case len(after) > 0:
+		c, err := DecodeCursor(after[0])
+		if err != nil {
+			return nil, CursorAfter, err
+		}
+		return c, CursorAfter, nil
+	case len(before) > 0:
+		c, err := DecodeCursor(before[0])
+		if err != nil {
+			return nil, CursorBefore, err
+		}
+		return c, CursorBefore, nil
+	}
+	return nil, CursorAfter, nil
+}
+
+// CursorToWhereClause generates a postgresql compatible keyset condition
+// along with the bind values for the given cursor, desc should be true if the
+// collection is sorted in descending order. The placeholders of the condition
+// are numbered after the offset, which is the number of bind values that
+// precede it in the query. An offset of zero is meant for the Where method of
+// dat runner.
+func CursorToWhereClause(sortCol, idCol string, c *Cursor, dir CursorDirection, desc bool, offset int) (string, []interface{}) {
+	op := ">"
+	if (dir == CursorBefore) != desc {
+		op = "<"
+	}
+	return fmt.Sprintf("(%s, %s) %s ($%d, $%d)", sortCol, idCol, op, offset+1, offset+2),
+		[]interface{}{c.Value, c.ID}
+}
+
+// CursorOrderBy generates the order by expression that has to accompany the
+// keyset condition. Records fetched before a cursor comes in reverse order
+// and has to be reversed before sending the response.
+func CursorOrderBy(sortCol, idCol string, dir CursorDirection, desc bool) string {
+	order := "ASC"
+	if (dir == CursorBefore) != desc {
+		order = "DESC"
+	}
+	return fmt.Sprintf("%s %s, %s %s", sortCol, order, idCol, order)
+}
+
+// AppendCursorParams appends cursor pagination query parameters to the url
+func AppendCursorParams(url, key, cursor string, pagesize int64) string {
+	return aphlink.New(url).
+		Page(fmt.Sprintf("page[%s]", key), cursor).
+		Page("pagesize", strconv.FormatInt(pagesize, 10)).
+		String()
+}
+
+// GenCursorLinks generates cursor paginated resource links. The current
+// cursor along with its direction is the one the page is fetched with, nil
+// for the first page. The first and last cursors are that of the records at
+// the boundary of the current page, a nil value of any of them skips the
+// corresponding link.
+func GenCursorLinks(url string, current *Cursor, dir CursorDirection, first, last *Cursor, pagesize int64) map[string]string {
+	links := make(map[string]string)
+	links["first"] = aphlink.New(url).Page("pagesize", strconv.FormatInt(pagesize, 10)).String()
+	links["self"] = links["first"]
+	if current != nil {
+		key := "after"
+		if dir == CursorBefore {
+			key = "before"
+		}
+		links["self"] = AppendCursorParams(url, key, EncodeCursor(current), pagesize)
+	}
+	if first != nil {
+		links["previous"] = AppendCursorParams(url, "before", EncodeCursor(first), pagesize)
+	}
+	if last != nil {
+		links["next"] = AppendCursorParams(url, "after", EncodeCursor(last), pagesize)
+	}
+	return links
+}
+
+// CursorScope generates the keyset condition from the cursor using the sort
+// and id columns of the service, see CursorToWhereClause for the offset
+func (s *Service) CursorScope(c *Cursor, dir CursorDirection, offset int) (string, []interface{}) {
+	return CursorToWhereClause(s.CursorSortKey, s.CursorIDKey, c, dir, s.CursorDesc, offset)
+}
+
+// CursorOrderBy generates the order by expression using the sort and id
+// columns of the service
+func (s *Service) CursorOrderBy(dir CursorDirection) string {
+	return CursorOrderBy(s.CursorSortKey, s.CursorIDKey, dir, s.CursorDesc)
+}
+
+// GetCursorPagination generates JSONAPI cursor based pagination links along
+// with fields, include, filter and sort query parameters. The prev and next cursors
+// are the boundary records of the current page, nil should be passed when
+// there is no page in that direction. The last link is never generated as it
+// is unknown without counting the entire collection. The self link is
+// generated from the cursor of the request context, see CursorFromContext.
+func (s *Service) GetCursorPagination(ctx context.Context, prev, next *Cursor, pagesize int64) *jsonapi.PaginationLinks {
+	current, dir, _ := CursorFromContext(ctx)
+	pageLinks := GenCursorLinks(s.GenCollResourceSelfLink(ctx), current, dir, prev, next, pagesize)
+	jsapiLinks := &jsonapi.PaginationLinks{
+		Self:  pageLinks["self"],
+		First: pageLinks["first"],
+	}
+	if _, ok := pageLinks["previous"]; ok {
+		jsapiLinks.Prev = pageLinks["previous"]
+	}
+	if _, ok := pageLinks["next"]; ok {
+		jsapiLinks.Next = pageLinks["next"]
+	}
+	return jsapiLinks
+}
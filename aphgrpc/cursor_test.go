@@ -0,0 +1,131 @@
+package aphgrpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tm := time.Date(2019, 3, 4, 5, 6, 7, 123456789, time.UTC)
+	cases := []struct {
+		value, id interface{}
+		exvalue   interface{}
+		exid      interface{}
+	}{
+		{tm, int64(10), tm, int64(10)},
+		{int32(42), "abc", int64(42), "abc"},
+		{float64(2.5), 9, float64(2.5), int64(9)},
+		{"name", "x/y", "name", "x/y"},
+		{true, int64(1), true, int64(1)},
+	}
+	for _, c := range cases {
+		dc, err := DecodeCursor(EncodeCursor(NewCursor(c.value, c.id)))
+		if err != nil {
+			t.Fatalf("error in decoding cursor %s", err)
+		}
+		if dt, ok := c.exvalue.(time.Time); ok {
+			v, ok := dc.Value.(time.Time)
+			if !ok || !v.Equal(dt) {
+				t.Fatalf("expected time value %s, got %#v", dt, dc.Value)
+			}
+		} else if dc.Value != c.exvalue {
+			t.Fatalf("expected value %#v, got %#v", c.exvalue, dc.Value)
+		}
+		if dc.ID != c.exid {
+			t.Fatalf("expected id %#v, got %#v", c.exid, dc.ID)
+		}
+	}
+}
+
+func TestDecodeCursorError(t *testing.T) {
+	for _, s := range []string{"%%", "bm90IGpzb24", "e30", "eyJ2Ijp7InQiOiJ4IiwidiI6MX0sImkiOnsidCI6InMiLCJ2IjoiYSJ9fQ"} {
+		if _, err := DecodeCursor(s); err == nil {
+			t.Fatalf("expected error for cursor %s", s)
+		}
+	}
+}
+
+func TestCursorToWhereClause(t *testing.T) {
+	c := NewCursor("a", int64(1))
+	cases := []struct {
+		dir    CursorDirection
+		desc   bool
+		offset int
+		clause string
+	}{
+		{CursorAfter, false, 0, "(name, id) > ($1, $2)"},
+		{CursorBefore, false, 0, "(name, id) < ($1, $2)"},
+		{CursorAfter, true, 2, "(name, id) < ($3, $4)"},
+		{CursorBefore, true, 1, "(name, id) > ($2, $3)"},
+	}
+	for _, tc := range cases {
+		clause, args := CursorToWhereClause("name", "id", c, tc.dir, tc.desc, tc.offset)
+		if clause != tc.clause {
+			t.Fatalf("expected clause %s, got %s", tc.clause, clause)
+		}
+		if len(args) != 2 || args[0] != "a" || args[1] != int64(1) {
+			t.Fatalf("unexpected args %v", args)
+		}
+	}
+}
+
+func cursorContext(r string) context.Context {
+	req := httptest.NewRequest("GET", "http://localhost/strains?"+r, nil)
+	return metadata.NewIncomingContext(context.Background(), CursorMetadata(context.Background(), req))
+}
+
+func TestCursorFromContext(t *testing.T) {
+	ec := EncodeCursor(NewCursor("a", int64(1)))
+	c, dir, err := CursorFromContext(cursorContext("page[before]=" + ec))
+	if err != nil {
+		t.Fatalf("error in reading cursor %s", err)
+	}
+	if dir != CursorBefore || c.Value != "a" || c.ID != int64(1) {
+		t.Fatalf("unexpected cursor %v in direction %d", c, dir)
+	}
+	for _, r := range []string{"filter=name==a", ""} {
+		c, dir, err = CursorFromContext(cursorContext(r))
+		if err != nil || c != nil || dir != CursorAfter {
+			t.Fatalf("expected no cursor, got %v %v", c, err)
+		}
+	}
+	if c, _, err := CursorFromContext(context.Background()); err != nil || c != nil {
+		t.Fatalf("expected no cursor, got %v %v", c, err)
+	}
+	for _, r := range []string{"page[after]=e30", "page[after]=" + ec + "&page[before]=" + ec} {
+		if _, _, err := CursorFromContext(cursorContext(r)); err == nil {
+			t.Fatalf("expected error for %s", r)
+		}
+	}
+}
+
+func TestGenCursorLinks(t *testing.T) {
+	url := "https://api.dictybase.org/strains"
+	first, last := NewCursor("a", int64(1)), NewCursor("c", int64(3))
+	links := GenCursorLinks(url, nil, CursorAfter, nil, last, 10)
+	if links["self"] != links["first"] {
+		t.Fatalf("expected self link of the first page %s, got %s", links["first"], links["self"])
+	}
+	if _, ok := links["previous"]; ok {
+		t.Fatal("unexpected previous link of the first page")
+	}
+	current := NewCursor("b", int64(2))
+	links = GenCursorLinks(url, current, CursorAfter, first, last, 10)
+	exself := AppendCursorParams(url, "after", EncodeCursor(current), 10)
+	if links["self"] != exself {
+		t.Fatalf("expected self link %s, got %s", exself, links["self"])
+	}
+	links = GenCursorLinks(url, current, CursorBefore, first, last, 10)
+	exself = AppendCursorParams(url, "before", EncodeCursor(current), 10)
+	if links["self"] != exself {
+		t.Fatalf("expected self link %s, got %s", exself, links["self"])
+	}
+	if links["previous"] != AppendCursorParams(url, "before", EncodeCursor(first), 10) ||
+		links["next"] != AppendCursorParams(url, "after", EncodeCursor(last), 10) {
+		t.Fatalf("unexpected previous and next links %v", links)
+	}
+}
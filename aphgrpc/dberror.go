@@ -0,0 +1,248 @@
+package aphgrpc
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	driver "github.com/arangodb/go-driver"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Reasons of the ErrorInfo details for the classified database errors
+const (
+	ReasonConstraint   = "CONSTRAINT_VIOLATION"
+	ReasonConflict     = "CONFLICT"
+	ReasonTimeout      = "TIMEOUT"
+	ReasonUnavailable  = "UNAVAILABLE"
+	ReasonInvalidValue = "INVALID_VALUE"
+	ReasonCancelled    = "CANCELLED"
+)
+
+var (
+	//ErrConstraint represents the violation of a foreign key, check or not null constraint
+	ErrConstraint = newError("Database constraint violation")
+	//ErrConflict represents a transaction that is aborted because of a concurrent one
+	ErrConflict = newError("Conflicting concurrent update")
+	//ErrTimeout represents a database operation that did not finish in time
+	ErrTimeout = newError("Database operation timed out")
+	//ErrUnavailable represents a database that could not be reached
+	ErrUnavailable = newError("Database is unavailable")
+	//ErrInvalidValue represents a value that is rejected by the database
+	ErrInvalidValue = newError("Invalid value for the database")
+	//ErrCancelled represents a request that is cancelled by the client
+	ErrCancelled = newError("Request cancelled")
+)
+
+// Postgres SQLSTATE codes, for the complete list see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation      = "23505"
+	pgForeignKeyViolation  = "23503"
+	pgCheckViolation       = "23514"
+	pgNotNullViolation     = "23502"
+	pgExclusionViolation   = "23P01"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+	pgQueryCanceled        = "57014"
+	pgLockNotAvailable     = "55P03"
+)
+
+// ArangoDB error numbers, for the complete list see
+// https://www.arangodb.com/docs/stable/appendix-error-codes.html
+const (
+	arangoConflict           = 1200
+	arangoDocumentNotFound   = 1202
+	arangoCollectionNotFound = 1203
+	arangoUniqueViolated     = 1210
+	arangoQueryKilled        = 1500
+	arangoLockTimeout        = 18
+)
+
+// sqlStater is implemented by the errors of pgx v4 and the newer releases
+// of lib/pq
+type sqlStater interface {
+	SQLState() string
+}
+
+// pqFielder is implemented by the errors of lib/pq, the SQLSTATE code is the
+// C field
+type pqFielder interface {
+	Get(byte) string
+}
+
+// timeouter is implemented by the network errors
+type timeouter interface {
+	Timeout() bool
+}
+
+// DBError is a database error classified by ClassifyDBError
+type DBError struct {
+	// Code is the gRPC code that is returned for the error
+	Code codes.Code
+	// Reason is set in the ErrorInfo details of the gRPC error
+	Reason string
+	// Trailer is the error trailer of the gRPC call
+	Trailer metadata.MD
+}
+
+// SQLState returns the SQLSTATE code of a postgres error, it is empty for
+// any other error
+func SQLState(err error) string {
+	var pe pgx.PgError
+	if errors.As(err, &pe) {
+		return pe.Code
+	}
+	var ppe *pgx.PgError
+	if errors.As(err, &ppe) && ppe != nil {
+		return ppe.Code
+	}
+	var se sqlStater
+	if errors.As(err, &se) {
+		return se.SQLState()
+	}
+	var pf pqFielder
+	if errors.As(err, &pf) {
+		return pf.Get('C')
+	}
+	return ""
+}
+
+// ClassifyDBError classifies the errors returned by the postgres(pgx and
+// lib/pq) and arangodb drivers along with the errors of database/sql and the
+// context of the call. The error is classified as Internal if it is not
+// recognized.
+func ClassifyDBError(err error) *DBError {
+	if dberr, ok := classifyPostgres(SQLState(err)); ok {
+		return dberr
+	}
+	if dberr, ok := classifyArango(err); ok {
+		return dberr
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows), strings.Contains(err.Error(), "no rows"):
+		return &DBError{codes.NotFound, ReasonNotFound, ErrNotFound}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &DBError{codes.DeadlineExceeded, ReasonTimeout, ErrTimeout}
+	case errors.Is(err, context.Canceled):
+		return &DBError{codes.Canceled, ReasonCancelled, ErrCancelled}
+	}
+	var te timeouter
+	if errors.As(err, &te) && te.Timeout() {
+		return &DBError{codes.DeadlineExceeded, ReasonTimeout, ErrTimeout}
+	}
+	return &DBError{codes.Internal, ReasonInternal, newError(err.Error())}
+}
+
+func classifyPostgres(state string) (*DBError, bool) {
+	if len(state) != 5 {
+		return nil, false
+	}
+	switch state {
+	case pgUniqueViolation:
+		return &DBError{codes.AlreadyExists, ReasonExists, ErrExists}, true
+	case pgForeignKeyViolation, pgCheckViolation, pgNotNullViolation, pgExclusionViolation:
+		return &DBError{codes.FailedPrecondition, ReasonConstraint, ErrConstraint}, true
+	case pgSerializationFailure, pgDeadlockDetected, pgLockNotAvailable:
+		return &DBError{codes.Aborted, ReasonConflict, ErrConflict}, true
+	case pgQueryCanceled:
+		return &DBError{codes.DeadlineExceeded, ReasonTimeout, ErrTimeout}, true
+	}
+	switch state[:2] {
+	// data exception
+	case "22":
+		return &DBError{codes.InvalidArgument, ReasonInvalidValue, ErrInvalidValue}, true
+	// connection exception, insufficient resources and operator intervention
+	case "08", "53", "57":
+		return &DBError{codes.Unavailable, ReasonUnavailable, ErrUnavailable}, true
+	}
+	return nil, false
+}
+
+func classifyArango(err error) (*DBError, bool) {
+	var ae driver.ArangoError
+	if !errors.As(driver.Cause(err), &ae) {
+		return nil, false
+	}
+	switch ae.ErrorNum {
+	case arangoUniqueViolated:
+		return &DBError{codes.AlreadyExists, ReasonExists, ErrExists}, true
+	case arangoConflict, arangoLockTimeout:
+		return &DBError{codes.Aborted, ReasonConflict, ErrConflict}, true
+	case arangoDocumentNotFound, arangoCollectionNotFound:
+		return &DBError{codes.NotFound, ReasonNotFound, ErrNotFound}, true
+	case arangoQueryKilled:
+		return &DBError{codes.DeadlineExceeded, ReasonTimeout, ErrTimeout}, true
+	}
+	switch ae.Code {
+	case 404:
+		return &DBError{codes.NotFound, ReasonNotFound, ErrNotFound}, true
+	case 409:
+		return &DBError{codes.AlreadyExists, ReasonExists, ErrExists}, true
+	case 412:
+		return &DBError{codes.Aborted, ReasonConflict, ErrConflict}, true
+	case 408, 504:
+		return &DBError{codes.DeadlineExceeded, ReasonTimeout, ErrTimeout}, true
+	case 503:
+		return &DBError{codes.Unavailable, ReasonUnavailable, ErrUnavailable}, true
+	}
+	return nil, false
+}
+
+// handleDBError returns the gRPC error for a classified database error, the
+// unrecognized errors are returned as Internal error with the given trailer
+// and reason
+func handleDBError(ctx context.Context, err error, md metadata.MD, reason string) error {
+	dberr := ClassifyDBError(err)
+	if dberr.Code == codes.Internal {
+		grpc.SetTrailer(ctx, md)
+		return newStatusError(ctx, codes.Internal, reason, err)
+	}
+	grpc.SetTrailer(ctx, dberr.Trailer)
+	switch dberr.Code {
+	case codes.NotFound:
+		return newStatusError(ctx, dberr.Code, dberr.Reason, err, resourceInfo(ctx, "resource not found")...)
+	case codes.AlreadyExists:
+		return newStatusError(ctx, dberr.Code, dberr.Reason, err, resourceInfo(ctx, "resource already exists")...)
+	}
+	return newStatusError(ctx, dberr.Code, dberr.Reason, err)
+}
+
+// httpStatus is the HTTP status of the gRPC status, the constraint
+// violations are conflicts with the current state of the resource, the
+// failed If-Match conditions are failed preconditions and the invalid
+// arguments of a failed media type negotiation are either not acceptable or
+// unsupported media types
+func httpStatus(s *status.Status) int {
+	for _, d := range s.Details() {
+		info, ok := d.(*errdetails.ErrorInfo)
+		if !ok {
+			continue
+		}
+		switch s.Code() {
+		case codes.FailedPrecondition:
+			switch info.Reason {
+			case ReasonConstraint:
+				return http.StatusConflict
+			case ReasonPrecondition:
+				return http.StatusPreconditionFailed
+			}
+		case codes.InvalidArgument:
+			switch info.Reason {
+			case ReasonNotAcceptable:
+				return http.StatusNotAcceptable
+			case ReasonUnsupportedMedia:
+				return http.StatusUnsupportedMediaType
+			}
+		}
+	}
+	return runtime.HTTPStatusFromCode(s.Code())
+}
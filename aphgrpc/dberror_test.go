@@ -0,0 +1,107 @@
+package aphgrpc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"testing"
+
+	driver "github.com/arangodb/go-driver"
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stateError fakes the errors of pgx v4 and lib/pq with a SQLSTATE method
+type stateError string
+
+func (e stateError) Error() string    { return "state error " + string(e) }
+func (e stateError) SQLState() string { return string(e) }
+
+// fieldError fakes the errors of the older releases of lib/pq
+type fieldError map[byte]string
+
+func (e fieldError) Error() string     { return "field error" }
+func (e fieldError) Get(k byte) string { return e[k] }
+
+// timeoutError fakes a network timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "i/o timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+func TestClassifyDBError(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{"pgx unique", pgx.PgError{Code: "23505"}, codes.AlreadyExists, ReasonExists},
+		{"pgx pointer foreign key", &pgx.PgError{Code: "23503"}, codes.FailedPrecondition, ReasonConstraint},
+		{"wrapped pgx serialization", errors.Wrap(pgx.PgError{Code: "40001"}, "update"), codes.Aborted, ReasonConflict},
+		{"wrapped pgx pointer data exception", fmt.Errorf("insert %w", &pgx.PgError{Code: "22001"}), codes.InvalidArgument, ReasonInvalidValue},
+		{"sqlstate not null", stateError("23502"), codes.FailedPrecondition, ReasonConstraint},
+		{"sqlstate cancelled", stateError("57014"), codes.DeadlineExceeded, ReasonTimeout},
+		{"sqlstate connection", stateError("08006"), codes.Unavailable, ReasonUnavailable},
+		{"pq field check", fieldError{'C': "23514"}, codes.FailedPrecondition, ReasonConstraint},
+		{"unknown sqlstate", stateError("42601"), codes.Internal, ReasonInternal},
+		{"arango unique", driver.ArangoError{ErrorNum: 1210}, codes.AlreadyExists, ReasonExists},
+		{"arango conflict", driver.ArangoError{ErrorNum: 1200}, codes.Aborted, ReasonConflict},
+		{"arango not found status", driver.ArangoError{Code: 404}, codes.NotFound, ReasonNotFound},
+		{"arango unavailable", driver.ArangoError{Code: 503}, codes.Unavailable, ReasonUnavailable},
+		{"no rows", errors.Wrap(sql.ErrNoRows, "get"), codes.NotFound, ReasonNotFound},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded, ReasonTimeout},
+		{"cancelled", errors.Wrap(context.Canceled, "query"), codes.Canceled, ReasonCancelled},
+		{"network timeout", timeoutError{}, codes.DeadlineExceeded, ReasonTimeout},
+		{"unknown", errors.New("boom"), codes.Internal, ReasonInternal},
+	}
+	for _, c := range cases {
+		dberr := ClassifyDBError(c.err)
+		if dberr.Code != c.code || dberr.Reason != c.reason {
+			t.Fatalf("%s: expected %s %s, got %s %s", c.name, c.code, c.reason, dberr.Code, dberr.Reason)
+		}
+	}
+}
+
+func TestSQLState(t *testing.T) {
+	var nilpe *pgx.PgError
+	cases := []struct {
+		err   error
+		state string
+	}{
+		{pgx.PgError{Code: "23505"}, "23505"},
+		{&pgx.PgError{Code: "40P01"}, "40P01"},
+		{errors.Wrap(&pgx.PgError{Code: "23503"}, "insert"), "23503"},
+		{stateError("55P03"), "55P03"},
+		{fieldError{'C': "22P02"}, "22P02"},
+		{nilpe, ""},
+		{errors.New("boom"), ""},
+	}
+	for _, c := range cases {
+		if state := SQLState(c.err); state != c.state {
+			t.Fatalf("expected state %q for %v, got %q", c.state, c.err, state)
+		}
+	}
+}
+
+func TestDBErrorHTTPStatus(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{pgx.PgError{Code: "23503"}, http.StatusConflict},
+		{pgx.PgError{Code: "23505"}, http.StatusConflict},
+		{sql.ErrNoRows, http.StatusNotFound},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		st, _ := status.FromError(handleDBError(ctx, c.err, ErrDatabaseQuery, ReasonDatabaseQuery))
+		if hs := httpStatus(st); hs != c.status {
+			t.Fatalf("expected http status %d for %v, got %d", c.status, c.err, hs)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package aphgrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the ErrorInfo details attached to the
+// errors
+var ErrorDomain = "dictybase.org"
+
+// Reasons of the ErrorInfo details, they are set as the code of the JSON API
+// error objects
+const (
+	ReasonDatabaseQuery  = "DATABASE_QUERY"
+	ReasonDatabaseInsert = "DATABASE_INSERT"
+	ReasonDatabaseUpdate = "DATABASE_UPDATE"
+	ReasonDatabaseDelete = "DATABASE_DELETE"
+	ReasonNotFound       = "NOT_FOUND"
+	ReasonExists         = "ALREADY_EXISTS"
+	ReasonInvalidParam   = "INVALID_PARAMETER"
+	ReasonFilterParam    = "INVALID_FILTER"
+	ReasonMessaging      = "MESSAGING"
+	ReasonInternal       = "INTERNAL"
+)
+
+// ContextKeyResource is the context key for the resource of a request
+var ContextKeyResource = contextKey("resource")
+
+// ContextKeyResourceID is the context key for the resource identifier of a
+// request
+var ContextKeyResourceID = contextKey("resourceID")
+
+// WithResource derives a context from the parent with the resource name and
+// identifier of the request, they are used for the ResourceInfo details of
+// not found and already exists errors. The id could be empty for
+// collections.
+func WithResource(ctx context.Context, name, id string) context.Context {
+	ctx = context.WithValue(ctx, ContextKeyResource, name)
+	if len(id) > 0 {
+		ctx = context.WithValue(ctx, ContextKeyResourceID, id)
+	}
+	return ctx
+}
+
+// AttributeViolation describes an invalid attribute of the resource object
+// in the request document, it becomes the source.pointer of the JSON API
+// error object
+func AttributeViolation(attr, desc string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{
+		Field:       "data.attributes." + attr,
+		Description: desc,
+	}
+}
+
+// ParamViolation describes an invalid query parameter, it becomes the
+// source.parameter of the JSON API error object
+func ParamViolation(param, desc string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{
+		Field:       param,
+		Description: desc,
+	}
+}
+
+// newStatusError creates a gRPC error with an ErrorInfo detail for the reason
+// followed by the given details. The details are skipped in the unlikely case
+// they could not be attached to the status.
+func newStatusError(ctx context.Context, code codes.Code, reason string, err error, details ...proto.Message) error {
+	return withDetails(ctx, status.New(code, err.Error()), reason, details...).Err()
+}
+
+// withDetails attaches the ErrorInfo detail for the reason and the given
+// details to the status
+func withDetails(ctx context.Context, st *status.Status, reason string, details ...proto.Message) *status.Status {
+	info := &errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorDomain,
+		Metadata: map[string]string{"id": errorID(ctx)},
+	}
+	dst, err := st.WithDetails(append([]proto.Message{info}, details...)...)
+	if err != nil {
+		return st
+	}
+	return dst
+}
+
+// resourceInfo creates the ResourceInfo detail from the resource of the
+// request context
+func resourceInfo(ctx context.Context, desc string) []proto.Message {
+	name, ok := ctx.Value(ContextKeyResource).(string)
+	if !ok {
+		return nil
+	}
+	id, _ := ctx.Value(ContextKeyResourceID).(string)
+	return []proto.Message{
+		&errdetails.ResourceInfo{
+			ResourceType: name,
+			ResourceName: id,
+			Description:  desc,
+		},
+	}
+}
+
+// errorID identifies an occurrence of an error, the x-request-id of the
+// incoming metadata is used if it is present
+func errorID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if id := firstMDValue(md, "x-request-id"); len(id) > 0 {
+			return id
+		}
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// violationSource maps the field of a violation to the source of a JSON API
+// error object. The query parameters become the parameter, anything else is
+// converted to a JSON pointer to the request document.
+func violationSource(field string) *ErrorSource {
+	switch {
+	case len(field) == 0:
+		return nil
+	case isQueryParam(field):
+		return &ErrorSource{Parameter: field}
+	case strings.HasPrefix(field, "/"):
+		return &ErrorSource{Pointer: field}
+	}
+	return &ErrorSource{Pointer: "/" + strings.Replace(field, ".", "/", -1)}
+}
+
+func isQueryParam(field string) bool {
+	switch field {
+	case "include", "fields", "field", "filter", "sort", "pagenum", "pagesize":
+		return true
+	}
+	return strings.HasPrefix(field, "page[") || strings.HasPrefix(field, "fields[")
+}
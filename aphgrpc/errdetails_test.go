@@ -0,0 +1,104 @@
+package aphgrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestViolationSource(t *testing.T) {
+	cases := []struct {
+		field string
+		src   *ErrorSource
+	}{
+		{"", nil},
+		{"filter", &ErrorSource{Parameter: "filter"}},
+		{"page[after]", &ErrorSource{Parameter: "page[after]"}},
+		{"fields[strains]", &ErrorSource{Parameter: "fields[strains]"}},
+		{"data.attributes.name", &ErrorSource{Pointer: "/data/attributes/name"}},
+		{"/data/type", &ErrorSource{Pointer: "/data/type"}},
+	}
+	for _, c := range cases {
+		src := violationSource(c.field)
+		if (src == nil) != (c.src == nil) || (src != nil && *src != *c.src) {
+			t.Fatalf("expected source %v for %s, got %v", c.src, c.field, src)
+		}
+	}
+}
+
+func TestFieldViolationError(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-1"))
+	err := HandleFieldViolationError(
+		ctx, errors.New("invalid attributes"),
+		AttributeViolation("name", "name is required"),
+		ParamViolation("include", "include is not allowed"),
+	)
+	st, _ := status.FromError(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("expected invalid argument, got %s", st.Code())
+	}
+	errs := jsonAPIErrors(ErrInValidParam, st)
+	if len(errs) != 2 {
+		t.Fatalf("expected an error object for every violation, got %v", errs)
+	}
+	for _, e := range errs {
+		if e.Code != ReasonInvalidParam || e.ID != "req-1" || e.Status != "400" {
+			t.Fatalf("unexpected error object %+v", e)
+		}
+	}
+	if errs[0].Source.Pointer != "/data/attributes/name" || errs[0].Detail != "name is required" {
+		t.Fatalf("unexpected attribute error object %+v", errs[0])
+	}
+	if errs[1].Source.Parameter != "include" {
+		t.Fatalf("unexpected parameter error object %+v", errs[1])
+	}
+	p := newProblem(nil, st)
+	if len(p.InvalidParams) != 2 || p.InvalidParams[0].Name != "/data/attributes/name" || p.InvalidParams[1].Name != "include" {
+		t.Fatalf("unexpected invalid params %v", p.InvalidParams)
+	}
+}
+
+func TestParamError(t *testing.T) {
+	st, _ := status.FromError(HandleParamError(context.Background(), ErrSortParam, errors.New("bad sort")))
+	var br *errdetails.BadRequest
+	var info *errdetails.ErrorInfo
+	for _, d := range st.Details() {
+		switch dt := d.(type) {
+		case *errdetails.BadRequest:
+			br = dt
+		case *errdetails.ErrorInfo:
+			info = dt
+		}
+	}
+	if info == nil || info.Reason != ReasonInvalidParam || info.Domain != ErrorDomain || len(info.Metadata["id"]) != 32 {
+		t.Fatalf("unexpected error info %v", info)
+	}
+	if br == nil || len(br.FieldViolations) != 1 || br.FieldViolations[0].Field != "sort" {
+		t.Fatalf("unexpected bad request %v", br)
+	}
+}
+
+func TestNotFoundResourceInfo(t *testing.T) {
+	ctx := WithResource(context.Background(), "strains", "DBS0236123")
+	st, _ := status.FromError(HandleNotFoundError(ctx, errors.New("no strain")))
+	if httpStatus(st) != http.StatusNotFound {
+		t.Fatalf("expected not found status, got %d", httpStatus(st))
+	}
+	errs := jsonAPIErrors(ErrNotFound, st)
+	meta, _ := errs[0].Meta.(map[string]interface{})
+	if errs[0].Code != ReasonNotFound || meta["resource_type"] != "strains" || meta["resource_name"] != "DBS0236123" {
+		t.Fatalf("unexpected error object %+v", errs[0])
+	}
+	st, _ = status.FromError(HandleNotFoundError(context.Background(), errors.New("no strain")))
+	for _, d := range st.Details() {
+		if _, ok := d.(*errdetails.ResourceInfo); ok {
+			t.Fatal("expected no resource info without the resource of the request")
+		}
+	}
+}
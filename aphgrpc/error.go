@@ -10,8 +10,10 @@ import (
 
 	context "golang.org/x/net/context"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -47,6 +49,8 @@ var (
 	ErrFields = newErrorWithParam("Invalid field query parameter", "field")
 	//ErrFilterParam represents any error with invalid filter query paramter
 	ErrFilterParam = newErrorWithParam("Invalid filter query parameter", "filter")
+	//ErrSortParam represents any error with invalid sort query paramter
+	ErrSortParam = newErrorWithParam("Invalid sort query parameter", "sort")
 	//ErrNotAcceptable represents any error with wrong or inappropriate http Accept header
 	ErrNotAcceptable = newError("Accept header is not acceptable")
 	//ErrUnsupportedMedia represents any error with unsupported media type in http header
@@ -111,14 +115,21 @@ func newError(msg string) metadata.MD {
 }
 
 // CustomHTTPError is a custom error handler for grpc-gateway to generate
-// JSONAPI formatted HTTP response.
-func CustomHTTPError(ctx context.Context, _ *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, _ *http.Request, err error) {
+// JSONAPI formatted HTTP response. An RFC 7807 problem document is generated
+// instead if the Accept header of the request prefers it.
+func CustomHTTPError(ctx context.Context, _ *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
 	md, ok := runtime.ServerMetadataFromContext(ctx)
 	if !ok {
-		fallbackError(w, getgRPCStatus(errors.Wrap(err, "unable to retrieve metadata")))
-		return
+		err = errors.Wrap(err, "unable to retrieve metadata")
+	}
+	switch {
+	case AcceptsProblem(r):
+		ProblemError(w, r, getgRPCStatus(err))
+	case !ok:
+		fallbackError(w, getgRPCStatus(err))
+	default:
+		JSONAPIError(w, md.TrailerMD, getgRPCStatus(err))
 	}
-	JSONAPIError(w, md.TrailerMD, getgRPCStatus(err))
 }
 
 func getgRPCStatus(err error) *status.Status {
@@ -129,23 +140,55 @@ func getgRPCStatus(err error) *status.Status {
 	return s
 }
 
-// JSONAPIError generates JSONAPI formatted error message
+// JSONAPIError generates JSONAPI formatted error message. The ErrorInfo
+// details of the status fill in the code and id of the error object, and
+// every field violation of the BadRequest details becomes an error object of
+// its own with the source of the violation.
 func JSONAPIError(w http.ResponseWriter, md metadata.MD, s *status.Status) {
-	status := runtime.HTTPStatusFromCode(s.Code())
+	w.Header().Set("Content-Type", JSONAPIMediaType)
+	w.WriteHeader(httpStatus(s))
+	encErr := json.NewEncoder(w).Encode(HTTPError{Errors: jsonAPIErrors(md, s)})
+	if encErr != nil {
+		http.Error(w, encErr.Error(), http.StatusInternalServerError)
+	}
+}
+
+func jsonAPIErrors(md metadata.MD, s *status.Status) []Error {
+	meta := map[string]interface{}{
+		"creator": "api error helper",
+	}
 	jsnErr := Error{
-		Status: strconv.Itoa(status),
-		Title:  strings.Join(md["error"], "-"),
+		Status: strconv.Itoa(httpStatus(s)),
+		Title:  strings.Join(md[MetaKey], "-"),
 		Detail: s.Message(),
-		Meta: map[string]interface{}{
-			"creator": "api error helper",
-		},
+		Meta:   meta,
 	}
-	w.Header().Set("Content-Type", "application/vnd.api+json")
-	w.WriteHeader(status)
-	encErr := json.NewEncoder(w).Encode(HTTPError{Errors: []Error{jsnErr}})
-	if encErr != nil {
-		http.Error(w, encErr.Error(), http.StatusInternalServerError)
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, d := range s.Details() {
+		switch dt := d.(type) {
+		case *errdetails.ErrorInfo:
+			jsnErr.Code = dt.Reason
+			jsnErr.ID = dt.Metadata["id"]
+		case *errdetails.ResourceInfo:
+			meta["resource_type"] = dt.ResourceType
+			if len(dt.ResourceName) > 0 {
+				meta["resource_name"] = dt.ResourceName
+			}
+		case *errdetails.BadRequest:
+			violations = append(violations, dt.FieldViolations...)
+		}
+	}
+	if len(violations) == 0 {
+		return []Error{jsnErr}
 	}
+	var jsnErrs []Error
+	for _, v := range violations {
+		e := jsnErr
+		e.Detail = v.Description
+		e.Source = violationSource(v.Field)
+		jsnErrs = append(jsnErrs, e)
+	}
+	return jsnErrs
 }
 
 func fallbackError(w http.ResponseWriter, s *status.Status) {
@@ -166,6 +209,11 @@ func fallbackError(w http.ResponseWriter, s *status.Status) {
 	}
 }
 
+// CheckNoRows reports whether the error is caused by the absence of any
+// record.
+//
+// Deprecated: use ClassifyDBError which recognizes the errors of the
+// database drivers.
 func CheckNoRows(err error) bool {
 	if strings.Contains(err.Error(), "no rows") {
 		return true
@@ -176,74 +224,100 @@ func CheckNoRows(err error) bool {
 func HandleMessagingError(ctx context.Context, st *spb.Status) error {
 	err := status.ErrorProto(st)
 	grpc.SetTrailer(ctx, newError(err.Error()))
-	return err
+	return withDetails(ctx, status.FromProto(st), ReasonMessaging).Err()
 }
 
+// HandleError classifies the database error with ClassifyDBError and sets
+// the matching trailer and gRPC code
 func HandleError(ctx context.Context, err error) error {
-	if CheckNoRows(err) {
-		grpc.SetTrailer(ctx, ErrNotFound)
-		return status.Error(codes.NotFound, err.Error())
-	}
-	grpc.SetTrailer(ctx, newError(err.Error()))
-	return status.Error(codes.Internal, err.Error())
+	return handleDBError(ctx, err, newError(err.Error()), ReasonInternal)
 }
 
 func HandleGenericError(ctx context.Context, err error) error {
 	grpc.SetTrailer(ctx, newError(err.Error()))
-	return status.Error(codes.Internal, err.Error())
+	return newStatusError(ctx, codes.Internal, ReasonInternal, err)
 }
 
 func HandleDeleteError(ctx context.Context, err error) error {
-	grpc.SetTrailer(ctx, ErrDatabaseDelete)
-	return status.Error(codes.Internal, err.Error())
+	return handleDBError(ctx, err, ErrDatabaseDelete, ReasonDatabaseDelete)
 }
 
 func HandleGetError(ctx context.Context, err error) error {
-	grpc.SetTrailer(ctx, ErrDatabaseQuery)
-	return status.Error(codes.Internal, err.Error())
+	return handleDBError(ctx, err, ErrDatabaseQuery, ReasonDatabaseQuery)
 }
 
 func HandleInsertError(ctx context.Context, err error) error {
-	grpc.SetTrailer(ctx, ErrDatabaseInsert)
-	return status.Error(codes.Internal, err.Error())
+	return handleDBError(ctx, err, ErrDatabaseInsert, ReasonDatabaseInsert)
 }
 
 func HandleUpdateError(ctx context.Context, err error) error {
-	grpc.SetTrailer(ctx, ErrDatabaseUpdate)
-	return status.Error(codes.Internal, err.Error())
+	return handleDBError(ctx, err, ErrDatabaseUpdate, ReasonDatabaseUpdate)
 }
 
 func HandleGetArgError(ctx context.Context, err error) error {
 	grpc.SetTrailer(ctx, ErrDatabaseQuery)
-	return status.Error(codes.InvalidArgument, err.Error())
+	return newStatusError(ctx, codes.InvalidArgument, ReasonDatabaseQuery, err)
 }
 
 func HandleInsertArgError(ctx context.Context, err error) error {
 	grpc.SetTrailer(ctx, ErrDatabaseInsert)
-	return status.Error(codes.InvalidArgument, err.Error())
+	return newStatusError(ctx, codes.InvalidArgument, ReasonDatabaseInsert, err)
 }
 
 func HandleUpdateArgError(ctx context.Context, err error) error {
 	grpc.SetTrailer(ctx, ErrDatabaseUpdate)
-	return status.Error(codes.InvalidArgument, err.Error())
+	return newStatusError(ctx, codes.InvalidArgument, ReasonDatabaseUpdate, err)
 }
 
 func HandleNotFoundError(ctx context.Context, err error) error {
 	grpc.SetTrailer(ctx, ErrNotFound)
-	return status.Error(codes.NotFound, err.Error())
+	return newStatusError(ctx, codes.NotFound, ReasonNotFound, err, resourceInfo(ctx, "resource not found")...)
 }
 
 func HandleExistError(ctx context.Context, err error) error {
 	grpc.SetTrailer(ctx, ErrExists)
-	return status.Error(codes.AlreadyExists, err.Error())
+	return newStatusError(ctx, codes.AlreadyExists, ReasonExists, err, resourceInfo(ctx, "resource already exists")...)
 }
 
 func HandleFilterParamError(ctx context.Context, err error) error {
 	grpc.SetTrailer(ctx, ErrFilterParam)
-	return status.Error(codes.InvalidArgument, err.Error())
+	return newStatusError(
+		ctx, codes.InvalidArgument, ReasonFilterParam, err,
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			ParamViolation("filter", err.Error()),
+		}},
+	)
 }
 
 func HandleInvalidParamError(ctx context.Context, err error) error {
 	grpc.SetTrailer(ctx, ErrInValidParam)
-	return status.Error(codes.InvalidArgument, err.Error())
+	return newStatusError(ctx, codes.InvalidArgument, ReasonInvalidParam, err)
+}
+
+// HandleFieldViolationError rejects the request with an InvalidArgument error
+// that carries the violations as BadRequest details, every violation is
+// turned into a separate JSON API error object by CustomHTTPError
+func HandleFieldViolationError(ctx context.Context, err error, violations ...*errdetails.BadRequest_FieldViolation) error {
+	grpc.SetTrailer(ctx, ErrInValidParam)
+	return newStatusError(
+		ctx, codes.InvalidArgument, ReasonInvalidParam, err,
+		&errdetails.BadRequest{FieldViolations: violations},
+	)
+}
+
+// HandleParamError rejects the request with an InvalidArgument error for an
+// invalid query parameter. The md is one of the error trailers of the query
+// parameters, such as ErrFilterParam, its parameter is added as BadRequest
+// details.
+func HandleParamError(ctx context.Context, md metadata.MD, err error) error {
+	grpc.SetTrailer(ctx, md)
+	var details []proto.Message
+	if v := md[MetaKey]; len(v) > 1 {
+		details = append(details, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				ParamViolation(v[1], err.Error()),
+			},
+		})
+	}
+	return newStatusError(ctx, codes.InvalidArgument, ReasonInvalidParam, err, details...)
 }
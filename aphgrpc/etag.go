@@ -0,0 +1,270 @@
+package aphgrpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ReasonPrecondition is the reason of the ErrorInfo detail for a failed
+// If-Match condition
+const ReasonPrecondition = "PRECONDITION_FAILED"
+
+// ContextKeyConditional is the context key for the conditional headers of
+// the HTTP request
+const ContextKeyConditional = contextKey("conditional")
+
+// ErrPrecondition represents a request whose If-Match header does not match the current resource
+var ErrPrecondition = newError("Precondition failed")
+
+// DefaultETagFields are the fields that hold the version of a resource
+var DefaultETagFields = []string{"version", "updated_at"}
+
+// ETag computes the strong entity tag of the message. It is derived from the
+// first of the version fields that is present in the attributes of the
+// resource, the resource itself or the message, matched with either the proto
+// or the JSON name. In their absence, it is derived from the deterministic
+// binary encoding of the whole message. The DefaultETagFields are used if no
+// field is given.
+func ETag(m proto.Message, fields ...string) (string, error) {
+	if len(fields) == 0 {
+		fields = DefaultETagFields
+	}
+	pm := proto.MessageReflect(m)
+	if !pm.IsValid() {
+		return "", fmt.Errorf("unable to compute etag of an empty message")
+	}
+	if v, ok := versionValue(pm, fields); ok {
+		return formatETag([]byte(v)), nil
+	}
+	b, err := protov2.MarshalOptions{Deterministic: true}.Marshal(proto.MessageV2(m))
+	if err != nil {
+		return "", fmt.Errorf("unable to encode message for etag %s", err)
+	}
+	return formatETag(b), nil
+}
+
+func formatETag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// versionValue returns the string form of the first version field that is
+// present in the attributes, the resource or the message
+func versionValue(m protoreflect.Message, fields []string) (string, bool) {
+	candidates := []protoreflect.Message{attributesMessage(m)}
+	if res, ok := messageField(m, "data"); ok {
+		candidates = append(candidates, res)
+	}
+	candidates = append(candidates, m)
+	for _, c := range candidates {
+		for _, name := range fields {
+			fd := lookupAttribute(c, name)
+			if fd == nil || fd.IsList() || fd.IsMap() || !c.Has(fd) {
+				continue
+			}
+			v := c.Get(fd)
+			if fd.Message() != nil {
+				b, err := protov2.MarshalOptions{Deterministic: true}.Marshal(v.Message().Interface())
+				if err != nil {
+					continue
+				}
+				return fmt.Sprintf("%s:%x", name, b), true
+			}
+			return fmt.Sprintf("%s:%v", name, v.Interface()), true
+		}
+	}
+	return "", false
+}
+
+// conditionalRequest keeps the conditional headers of the HTTP request for
+// the forward response options of the gateway
+type conditionalRequest struct {
+	method      string
+	ifNoneMatch string
+}
+
+// conditionalWriter drops the body and the status of the response once it is
+// answered with 304
+type conditionalWriter struct {
+	http.ResponseWriter
+	notModified bool
+}
+
+func (cw *conditionalWriter) WriteHeader(code int) {
+	if cw.notModified {
+		return
+	}
+	if code == http.StatusNotModified {
+		cw.notModified = true
+		h := cw.Header()
+		for _, k := range []string{"Content-Type", "Content-Length", "Trailer"} {
+			h.Del(k)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *conditionalWriter) Write(b []byte) (int, error) {
+	if cw.notModified {
+		return len(b), nil
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush keeps the streaming responses of the gateway working
+func (cw *conditionalWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok && !cw.notModified {
+		f.Flush()
+	}
+}
+
+// ConditionalHandler is an HTTP middleware for the gateway mux that makes
+// the If-None-Match header of the request available to ETagResponse. The
+// If-Match header needs no middleware, it is passed on to the gRPC handlers
+// by the gateway.
+func ConditionalHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cr := &conditionalRequest{
+			method:      r.Method,
+			ifNoneMatch: r.Header.Get("If-None-Match"),
+		}
+		ctx := context.WithValue(r.Context(), ContextKeyConditional, cr)
+		next.ServeHTTP(&conditionalWriter{ResponseWriter: w}, r.WithContext(ctx))
+	})
+}
+
+// ETagResponse returns a forward response option of the grpc gateway that
+// sets the ETag header of the response computed from the given fields, see
+// ETag. A GET or HEAD request is answered with 304 if its If-None-Match
+// header matches the ETag, which requires the gateway mux to be wrapped in
+// ConditionalHandler. It has to be registered before HandleResponse. The
+// error in computing the ETag is returned, which fails the response.
+func ETagResponse(fields ...string) func(context.Context, http.ResponseWriter, proto.Message) error {
+	return func(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+		if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+			if rm, ok := ResponseMetaFromMD(md.TrailerMD); ok && rm.Status == http.StatusNoContent {
+				return nil
+			}
+		}
+		etag, err := ETag(resp, fields...)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("ETag", etag)
+		cr, ok := ctx.Value(ContextKeyConditional).(*conditionalRequest)
+		if !ok || (cr.method != http.MethodGet && cr.method != http.MethodHead) {
+			return nil
+		}
+		if matchETag(cr.ifNoneMatch, etag, true) {
+			w.WriteHeader(http.StatusNotModified)
+		}
+		return nil
+	}
+}
+
+// ConditionalResource is implemented by services that enforce the If-Match
+// header of their update and delete requests
+type ConditionalResource interface {
+	// CurrentResource returns the current state of the resource of the
+	// request, it should be the same message as the response of its GET
+	// request so that both have the same ETag
+	CurrentResource(ctx context.Context, req interface{}) (proto.Message, error)
+}
+
+// ConditionalInterceptor returns a unary server interceptor that enforces
+// the If-Match header of the update and delete requests, which are
+// recognized from the Update and Delete prefixes of the method name. It is
+// applied to the services that implement ConditionalResource.
+func ConditionalInterceptor(fields ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		cr, ok := info.Server.(ConditionalResource)
+		if !ok || len(ifMatch(ctx)) == 0 {
+			return handler(ctx, req)
+		}
+		name := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+		if !strings.HasPrefix(name, "Update") && !strings.HasPrefix(name, "Delete") {
+			return handler(ctx, req)
+		}
+		current, err := cr.CurrentResource(ctx, req)
+		if err != nil {
+			return nil, HandleGetError(ctx, err)
+		}
+		if err := CheckIfMatch(ctx, current, fields...); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// CheckIfMatch compares the If-Match header of the request with the ETag of
+// the current resource. It returns a FailedPrecondition error, which is
+// rendered as 412 by the gateway, if they do not match. The check passes if
+// the request has no If-Match header.
+func CheckIfMatch(ctx context.Context, current proto.Message, fields ...string) error {
+	header := ifMatch(ctx)
+	if len(header) == 0 {
+		return nil
+	}
+	etag, err := ETag(current, fields...)
+	if err != nil {
+		return HandleError(ctx, err)
+	}
+	if matchETag(header, etag, false) {
+		return nil
+	}
+	grpc.SetTrailer(ctx, ErrPrecondition)
+	return newStatusError(
+		ctx, codes.FailedPrecondition, ReasonPrecondition,
+		fmt.Errorf("If-Match %s does not match the current etag %s", header, etag),
+		resourceInfo(ctx, "resource has been modified")...,
+	)
+}
+
+// ifMatch returns the If-Match header forwarded by the gateway or sent
+// directly by a gRPC client
+func ifMatch(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, k := range []string{strings.ToLower(runtime.MetadataPrefix + "If-Match"), "if-match"} {
+		if v, ok := md[k]; ok && len(v) > 0 {
+			return strings.Join(v, ",")
+		}
+	}
+	return ""
+}
+
+// matchETag reports whether the etag matches any entity tag of the header
+// value, the weak comparison ignores the W/ prefix whereas the strong
+// comparison never matches a weak tag
+func matchETag(header, etag string, weak bool) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			return true
+		}
+		if strings.HasPrefix(t, "W/") {
+			if !weak {
+				continue
+			}
+			t = strings.TrimPrefix(t, "W/")
+		}
+		if t == etag {
+			return true
+		}
+	}
+	return false
+}
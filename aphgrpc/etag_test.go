@@ -0,0 +1,149 @@
+package aphgrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func etagResource(t *testing.T, updated time.Time) proto.Message {
+	return testRequest(t, func(attrs protoreflect.Message) {
+		setField(attrs, "name", protoreflect.ValueOfString("sadA"))
+		setField(attrs, "updated_at", timestampValue(updated))
+	})
+}
+
+func TestETag(t *testing.T) {
+	now := time.Now()
+	a, err := ETag(etagResource(t, now))
+	if err != nil {
+		t.Fatalf("error in computing etag %s", err)
+	}
+	b, _ := ETag(etagResource(t, now))
+	c, _ := ETag(etagResource(t, now.Add(time.Second)))
+	if a != b || a == c {
+		t.Fatalf("expected etag to follow updated_at, got %s %s %s", a, b, c)
+	}
+	if len(a) != 34 || a[0] != '"' || a[len(a)-1] != '"' {
+		t.Fatalf("expected a quoted strong etag, got %s", a)
+	}
+	if _, err := ETag((*timestamppb.Timestamp)(nil)); err == nil {
+		t.Fatal("expected error for an empty message")
+	}
+}
+
+func TestMatchETag(t *testing.T) {
+	etag := `"abc"`
+	cases := []struct {
+		header      string
+		weak, match bool
+	}{
+		{`"abc"`, false, true},
+		{`W/"abc"`, false, false},
+		{`W/"abc"`, true, true},
+		{`"xyz", W/"abc"`, true, true},
+		{`"xyz", W/"abc"`, false, false},
+		{`*`, false, true},
+		{`"xyz"`, true, false},
+	}
+	for _, c := range cases {
+		if m := matchETag(c.header, etag, c.weak); m != c.match {
+			t.Fatalf("expected match %t for %s weak %t", c.match, c.header, c.weak)
+		}
+	}
+}
+
+// serveETag runs the forward response option for the resource behind the
+// conditional handler and writes the body as the gateway does
+func serveETag(t *testing.T, method, ifNoneMatch string, resp proto.Message) *httptest.ResponseRecorder {
+	h := ConditionalHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := ETagResponse()(r.Context(), w, resp); err != nil {
+			t.Fatalf("error in forward response option %s", err)
+		}
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	r := httptest.NewRequest(method, "http://localhost/strains/1", nil)
+	if len(ifNoneMatch) > 0 {
+		r.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestETagResponse(t *testing.T) {
+	resp := etagResource(t, time.Now())
+	etag, _ := ETag(resp)
+	cases := []struct {
+		method, ifNoneMatch string
+		status              int
+	}{
+		{http.MethodGet, "", http.StatusOK},
+		{http.MethodGet, etag, http.StatusNotModified},
+		{http.MethodGet, "W/" + etag, http.StatusNotModified},
+		{http.MethodHead, etag, http.StatusNotModified},
+		{http.MethodGet, `"stale"`, http.StatusOK},
+		{http.MethodPatch, etag, http.StatusOK},
+	}
+	for _, c := range cases {
+		w := serveETag(t, c.method, c.ifNoneMatch, resp)
+		if w.Code != c.status {
+			t.Fatalf("expected status %d for %s %s, got %d", c.status, c.method, c.ifNoneMatch, w.Code)
+		}
+		if w.Header().Get("ETag") != etag {
+			t.Fatalf("expected etag %s, got %s", etag, w.Header().Get("ETag"))
+		}
+		if c.status == http.StatusNotModified && w.Body.Len() > 0 {
+			t.Fatalf("expected no body for 304, got %s", w.Body.String())
+		}
+		if c.status == http.StatusOK && w.Body.Len() == 0 {
+			t.Fatal("expected the body for 200")
+		}
+	}
+	w := httptest.NewRecorder()
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		TrailerMD: metadata.Pairs(StatusKey, "204"),
+	})
+	if err := ETagResponse()(ctx, w, resp); err != nil || len(w.Header().Get("ETag")) > 0 {
+		t.Fatalf("expected no etag for 204, got %s %v", w.Header().Get("ETag"), err)
+	}
+	if err := ETagResponse()(context.Background(), httptest.NewRecorder(), (*timestamppb.Timestamp)(nil)); err == nil {
+		t.Fatal("expected the error in computing the etag")
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	current := etagResource(t, time.Now())
+	etag, _ := ETag(current)
+	ifMatchCtx := func(key, value string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(key, value))
+	}
+	if err := CheckIfMatch(context.Background(), current); err != nil {
+		t.Fatalf("expected no error without If-Match, got %s", err)
+	}
+	for _, key := range []string{"grpcgateway-if-match", "if-match"} {
+		if err := CheckIfMatch(ifMatchCtx(key, etag), current); err != nil {
+			t.Fatalf("expected matching etag in %s, got %s", key, err)
+		}
+	}
+	if err := CheckIfMatch(ifMatchCtx("if-match", "*"), current); err != nil {
+		t.Fatalf("expected any etag to match *, got %s", err)
+	}
+	for _, header := range []string{`"stale"`, "W/" + etag} {
+		err := CheckIfMatch(ifMatchCtx("grpcgateway-if-match", header), current)
+		st, _ := status.FromError(err)
+		if st.Code() != codes.FailedPrecondition || httpStatus(st) != http.StatusPreconditionFailed {
+			t.Fatalf("expected 412 for If-Match %s, got %v", header, err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package aphgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dictyBase/apihelpers/pubsub"
+	"gopkg.in/mgutz/dat.v2/sqlx-runner"
+)
+
+// Actions of the mutation events, they are the keys of the Topics of the
+// service
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// ContextKeyEvents is the context key for the queue of the deferred events
+const ContextKeyEvents = contextKey("events")
+
+// Event is the envelope of a mutation event, it is published as JSON
+type Event struct {
+	// Resource is the name of the mutated resource
+	Resource string `json:"resource"`
+	// ID of the mutated resource
+	ID string `json:"id"`
+	// Action is one of create, update or delete
+	Action string `json:"action"`
+	// Fields are the changed attributes
+	Fields []string `json:"fields,omitempty"`
+	// Timestamp of the mutation
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PublisherOption sets the publisher of the mutation events
+func PublisherOption(p pubsub.Publisher) Option {
+	return func(so *ServiceOptions) {
+		so.Publisher = p
+	}
+}
+
+// NewEvent creates the event of a mutation of the resource of the service
+func (s *Service) NewEvent(action string, id interface{}, fields ...string) *Event {
+	return &Event{
+		Resource:  s.Resource,
+		ID:        idString(id),
+		Action:    action,
+		Fields:    fields,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// EmitEvent publishes the event of a successful mutation to the topic of its
+// action. Nothing is published if the service has no publisher or no topic
+// for the action. The event is queued if the context is from DeferEvents,
+// so that it is published only after the transaction is committed.
+func (s *Service) EmitEvent(ctx context.Context, action string, id interface{}, fields ...string) error {
+	topic, ok := s.Topics[action]
+	if !ok || s.Publisher == nil {
+		return nil
+	}
+	ev := s.NewEvent(action, id, fields...)
+	if q, ok := ctx.Value(ContextKeyEvents).(*EventQueue); ok {
+		q.add(s.Publisher, topic, ev)
+		return nil
+	}
+	return publishEvent(s.Publisher, topic, ev)
+}
+
+// RunInTx runs the function in a database transaction, which is committed
+// if the function succeeds and rolled back otherwise. The events emitted with
+// the context passed to the function are published only after the commit.
+func (s *Service) RunInTx(ctx context.Context, fn func(context.Context, *runner.Tx) error) error {
+	tx, err := s.Dbh.Begin()
+	if err != nil {
+		return err
+	}
+	ctx, q := DeferEvents(ctx)
+	if err := fn(ctx, tx); err != nil {
+		q.Discard()
+		if rerr := tx.Rollback(); rerr != nil {
+			return fmt.Errorf("%s, error in rollback %s", err, rerr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		q.Discard()
+		return err
+	}
+	return q.Publish()
+}
+
+// EventQueue keeps the events of a transaction till it is committed. It is
+// safe for concurrent use.
+type EventQueue struct {
+	mu     sync.Mutex
+	events []*queuedEvent
+}
+
+type queuedEvent struct {
+	publisher pubsub.Publisher
+	topic     string
+	event     *Event
+}
+
+// DeferEvents returns a context in which the emitted events are queued
+// instead of being published
+func DeferEvents(ctx context.Context) (context.Context, *EventQueue) {
+	q := &EventQueue{}
+	return context.WithValue(ctx, ContextKeyEvents, q), q
+}
+
+func (q *EventQueue) add(p pubsub.Publisher, topic string, ev *Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.events = append(q.events, &queuedEvent{publisher: p, topic: topic, event: ev})
+}
+
+// Events returns the queued events
+func (q *EventQueue) Events() []*Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var events []*Event
+	for _, qe := range q.events {
+		events = append(events, qe.event)
+	}
+	return events
+}
+
+// Publish publishes the queued events in their order and empties the queue,
+// it is meant to be called after the transaction is committed. All the
+// events are attempted and the first error is returned.
+func (q *EventQueue) Publish() error {
+	q.mu.Lock()
+	events := q.events
+	q.events = nil
+	q.mu.Unlock()
+	var perr error
+	for _, qe := range events {
+		if err := publishEvent(qe.publisher, qe.topic, qe.event); err != nil && perr == nil {
+			perr = err
+		}
+	}
+	return perr
+}
+
+// Discard empties the queue without publishing, it is meant to be called
+// after the transaction is rolled back
+func (q *EventQueue) Discard() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.events = nil
+}
+
+func publishEvent(p pubsub.Publisher, topic string, ev *Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("unable to encode event %s", err)
+	}
+	if err := p.PublishRaw(topic, data); err != nil {
+		return fmt.Errorf("unable to publish event to %s %s", topic, err)
+	}
+	return nil
+}
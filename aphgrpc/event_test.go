@@ -0,0 +1,135 @@
+package aphgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// fakePublisher records the published events, publishing to the failing
+// topic fails
+type fakePublisher struct {
+	failing string
+	topics  []string
+	events  []*Event
+}
+
+func (p *fakePublisher) Publish(topic string, m proto.Message) error {
+	return errors.New("proto messages are not expected")
+}
+
+func (p *fakePublisher) PublishRaw(topic string, data []byte) error {
+	if topic == p.failing {
+		return errors.New("publish failed")
+	}
+	ev := &Event{}
+	if err := json.Unmarshal(data, ev); err != nil {
+		return err
+	}
+	p.topics = append(p.topics, topic)
+	p.events = append(p.events, ev)
+	return nil
+}
+
+func eventService(p *fakePublisher) *Service {
+	return &Service{
+		Resource:  "strains",
+		Publisher: p,
+		Topics: map[string]string{
+			ActionCreate: "strain.create",
+			ActionUpdate: "strain.update",
+			ActionDelete: "strain.delete",
+		},
+	}
+}
+
+func TestEmitEvent(t *testing.T) {
+	p := &fakePublisher{}
+	s := eventService(p)
+	if err := s.EmitEvent(context.Background(), ActionUpdate, int64(7), "name", "label"); err != nil {
+		t.Fatalf("error in emitting event %s", err)
+	}
+	if len(p.events) != 1 || p.topics[0] != "strain.update" {
+		t.Fatalf("expected an event on strain.update, got %v", p.topics)
+	}
+	ev := p.events[0]
+	if ev.Resource != "strains" || ev.ID != "7" || ev.Action != ActionUpdate ||
+		!reflect.DeepEqual(ev.Fields, []string{"name", "label"}) || ev.Timestamp.IsZero() {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	delete(s.Topics, ActionDelete)
+	if err := s.EmitEvent(context.Background(), ActionDelete, int64(7)); err != nil || len(p.events) != 1 {
+		t.Fatalf("expected no event without a topic, got %v %v", p.topics, err)
+	}
+	s.Publisher = nil
+	if err := s.EmitEvent(context.Background(), ActionCreate, int64(8)); err != nil {
+		t.Fatalf("expected no error without a publisher, got %s", err)
+	}
+}
+
+func TestEventQueuePublish(t *testing.T) {
+	p := &fakePublisher{}
+	s := eventService(p)
+	ctx, q := DeferEvents(context.Background())
+	for i, action := range []string{ActionCreate, ActionUpdate, ActionDelete} {
+		if err := s.EmitEvent(ctx, action, i); err != nil {
+			t.Fatalf("error in emitting event %s", err)
+		}
+	}
+	if len(p.events) != 0 {
+		t.Fatalf("expected the events to be deferred, got %v", p.topics)
+	}
+	if n := len(q.Events()); n != 3 {
+		t.Fatalf("expected 3 queued events, got %d", n)
+	}
+	if err := q.Publish(); err != nil {
+		t.Fatalf("error in publishing events %s", err)
+	}
+	if !reflect.DeepEqual(p.topics, []string{"strain.create", "strain.update", "strain.delete"}) {
+		t.Fatalf("expected the events in their order, got %v", p.topics)
+	}
+	for i, ev := range p.events {
+		if ev.ID != []string{"0", "1", "2"}[i] {
+			t.Fatalf("unexpected event %d %+v", i, ev)
+		}
+	}
+	if len(q.Events()) != 0 {
+		t.Fatal("expected the queue to be emptied after publishing")
+	}
+	if err := q.Publish(); err != nil || len(p.events) != 3 {
+		t.Fatalf("expected nothing to be published again, got %v %v", p.topics, err)
+	}
+}
+
+func TestEventQueuePublishError(t *testing.T) {
+	p := &fakePublisher{failing: "strain.create"}
+	s := eventService(p)
+	ctx, q := DeferEvents(context.Background())
+	s.EmitEvent(ctx, ActionCreate, 1)
+	s.EmitEvent(ctx, ActionUpdate, 2)
+	if err := q.Publish(); err == nil {
+		t.Fatal("expected the error of the failed event")
+	}
+	if !reflect.DeepEqual(p.topics, []string{"strain.update"}) {
+		t.Fatalf("expected the remaining events to be published, got %v", p.topics)
+	}
+}
+
+func TestEventQueueDiscard(t *testing.T) {
+	p := &fakePublisher{}
+	s := eventService(p)
+	ctx, q := DeferEvents(context.Background())
+	s.EmitEvent(ctx, ActionCreate, 1)
+	s.EmitEvent(ctx, ActionUpdate, 1)
+	q.Discard()
+	if len(q.Events()) != 0 {
+		t.Fatal("expected the queue to be empty after discarding")
+	}
+	if err := q.Publish(); err != nil || len(p.events) != 0 {
+		t.Fatalf("expected no event after discarding, got %v %v", p.topics, err)
+	}
+}
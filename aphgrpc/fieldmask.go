@@ -0,0 +1,128 @@
+package aphgrpc
+
+import (
+	"context"
+
+	"github.com/dictyBase/apihelpers/aphcollection"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FieldsToFieldMask converts the attributes of the sparse fieldsets to a
+// field mask, the paths of the mask are relative to the attributes of the
+// resource
+func FieldsToFieldMask(fields []string) *field_mask.FieldMask {
+	return &field_mask.FieldMask{Paths: append([]string{}, fields...)}
+}
+
+// FieldMaskFromContext returns the field mask of the sparse fieldsets of the
+// request, it is false if the request does not have the fields parameter
+func FieldMaskFromContext(ctx context.Context) (*field_mask.FieldMask, bool) {
+	ri := RequestInfoFromContext(ctx)
+	if !ri.HasParams() || !ri.Params.HasFields {
+		return nil, false
+	}
+	return FieldsToFieldMask(ri.Params.Fields), true
+}
+
+// MaskToColumns maps the paths of the field mask to the storage columns, the
+// columns are meant to be the SELECT list of the query. The paths without
+// any column are skipped.
+func (s *Service) MaskToColumns(mask *field_mask.FieldMask) []string {
+	var columns []string
+	for _, p := range mask.GetPaths() {
+		if c, ok := s.FieldsToColumns[p]; ok {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// PruneAttributes clears every attribute of the JSON API response message
+// that is absent in the field mask. The attributes are looked up in the
+// data.attributes message of a single resource or in that of every member
+// of a collection, falling back to the message itself. The paths are
+// matched with either the proto or the JSON name of the fields.
+func PruneAttributes(m proto.Message, mask *field_mask.FieldMask) {
+	if m == nil || mask == nil {
+		return
+	}
+	pm := proto.MessageReflect(m)
+	if !pm.IsValid() {
+		return
+	}
+	for _, attrs := range responseAttributes(pm) {
+		pruneMessage(attrs, mask.GetPaths())
+	}
+}
+
+// responseAttributes returns the attributes messages of a single or
+// collection resource
+func responseAttributes(m protoreflect.Message) []protoreflect.Message {
+	fd := m.Descriptor().Fields().ByName("data")
+	if fd == nil {
+		return messageAttributes(m, true)
+	}
+	if fd.Message() == nil || fd.IsMap() || !m.Has(fd) {
+		return nil
+	}
+	if !fd.IsList() {
+		return messageAttributes(m.Get(fd).Message(), false)
+	}
+	var attrs []protoreflect.Message
+	l := m.Get(fd).List()
+	for i := 0; i < l.Len(); i++ {
+		attrs = append(attrs, messageAttributes(l.Get(i).Message(), false)...)
+	}
+	return attrs
+}
+
+// messageAttributes returns the attributes message of a resource object, the
+// message itself is returned in its absence if self is true
+func messageAttributes(m protoreflect.Message, self bool) []protoreflect.Message {
+	fd := m.Descriptor().Fields().ByName("attributes")
+	switch {
+	case fd == nil && self:
+		return []protoreflect.Message{m}
+	case fd == nil || fd.Message() == nil || fd.IsList() || fd.IsMap() || !m.Has(fd):
+		return nil
+	}
+	return []protoreflect.Message{m.Get(fd).Message()}
+}
+
+func pruneMessage(m protoreflect.Message, paths []string) {
+	var clear []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if !aphcollection.Contains(paths, string(fd.Name())) &&
+			!aphcollection.Contains(paths, fd.JSONName()) {
+			clear = append(clear, fd)
+		}
+		return true
+	})
+	for _, fd := range clear {
+		m.Clear(fd)
+	}
+}
+
+// SparseFieldsetInterceptor returns a unary server interceptor that prunes
+// the attributes of the response which are absent in the sparse fieldsets of
+// the request. It reads the parameters parsed by JSONAPIParamsInterceptor, so
+// it has to be chained after that one.
+func SparseFieldsetInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		mask, ok := FieldMaskFromContext(ctx)
+		if !ok {
+			return resp, err
+		}
+		if m, ok := resp.(proto.Message); ok {
+			PruneAttributes(m, mask)
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,117 @@
+package aphgrpc
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func fullAttributes(attrs protoreflect.Message) {
+	setField(attrs, "name", protoreflect.ValueOfString("sadA"))
+	setField(attrs, "count", protoreflect.ValueOfInt64(3))
+	setField(attrs, "updated_at", timestampValue(time.Now()))
+	setField(attrs, "label", stringValue("axenic"))
+	setTags(attrs, "a", "b")
+}
+
+// setAttributes returns the sorted names of the attributes that are set
+func setAttributes(m protoreflect.Message) []string {
+	var names []string
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		names = append(names, string(fd.Name()))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+func TestFieldsToFieldMask(t *testing.T) {
+	fields := []string{"name", "updatedAt"}
+	mask := FieldsToFieldMask(fields)
+	fields[0] = "label"
+	if !reflect.DeepEqual(mask.Paths, []string{"name", "updatedAt"}) {
+		t.Fatalf("unexpected paths %v", mask.Paths)
+	}
+	if _, ok := FieldMaskFromContext(context.Background()); ok {
+		t.Fatal("expected no mask without the fields parameter")
+	}
+	params := &JSONAPIParams{HasFields: true, Fields: []string{"name"}}
+	ctx := context.WithValue(context.Background(), ContextKeyParams, params)
+	mask, ok := FieldMaskFromContext(ctx)
+	if !ok || !reflect.DeepEqual(mask.Paths, []string{"name"}) {
+		t.Fatalf("unexpected mask %v", mask)
+	}
+	s := &Service{FieldsToColumns: map[string]string{"name": "strain.name", "label": "strain.label"}}
+	cols := s.MaskToColumns(FieldsToFieldMask([]string{"name", "unknown", "label"}))
+	if !reflect.DeepEqual(cols, []string{"strain.name", "strain.label"}) {
+		t.Fatalf("unexpected columns %v", cols)
+	}
+}
+
+func TestPruneAttributes(t *testing.T) {
+	req := testRequest(t, fullAttributes)
+	// the paths are matched with the proto and the JSON names
+	PruneAttributes(req, FieldsToFieldMask([]string{"name", "updatedAt"}))
+	names := setAttributes(attributesMessage(req))
+	if !reflect.DeepEqual(names, []string{"name", "updated_at"}) {
+		t.Fatalf("unexpected attributes %v", names)
+	}
+	data := req.Get(req.Descriptor().Fields().ByName("data")).Message()
+	if tp := data.Get(data.Descriptor().Fields().ByName("type")).String(); tp != "strains" {
+		t.Fatalf("expected resource type to be kept, got %s", tp)
+	}
+
+	msgs := testFile(t).Messages()
+	coll := dynamicpb.NewMessage(msgs.ByName("Coll"))
+	l := coll.Mutable(coll.Descriptor().Fields().ByName("data")).List()
+	for i := 0; i < 2; i++ {
+		member := dynamicpb.NewMessage(msgs.ByName("Data"))
+		attrs := dynamicpb.NewMessage(msgs.ByName("Attrs"))
+		fullAttributes(attrs)
+		setField(member, "attributes", protoreflect.ValueOfMessage(attrs))
+		l.Append(protoreflect.ValueOfMessage(member))
+	}
+	PruneAttributes(coll, FieldsToFieldMask([]string{"tags"}))
+	for i := 0; i < l.Len(); i++ {
+		member := l.Get(i).Message()
+		attrs := member.Get(member.Descriptor().Fields().ByName("attributes")).Message()
+		if names := setAttributes(attrs); !reflect.DeepEqual(names, []string{"tags"}) {
+			t.Fatalf("unexpected attributes of member %d %v", i, names)
+		}
+	}
+
+	attrs := dynamicpb.NewMessage(msgs.ByName("Attrs"))
+	fullAttributes(attrs)
+	PruneAttributes(attrs, FieldsToFieldMask([]string{"count"}))
+	if names := setAttributes(attrs); !reflect.DeepEqual(names, []string{"count"}) {
+		t.Fatalf("unexpected attributes of the message itself %v", names)
+	}
+}
+
+func TestSparseFieldsetInterceptor(t *testing.T) {
+	req := testRequest(t, fullAttributes)
+	handler := func(ctx context.Context, r interface{}) (interface{}, error) {
+		return req, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/strain.StrainService/GetStrain"}
+	if _, err := SparseFieldsetInterceptor()(context.Background(), nil, info, handler); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if names := setAttributes(attributesMessage(req)); len(names) != 5 {
+		t.Fatalf("expected the attributes to be untouched without fields, got %v", names)
+	}
+	params := &JSONAPIParams{HasFields: true, Fields: []string{"label"}}
+	ctx := context.WithValue(context.Background(), ContextKeyParams, params)
+	if _, err := SparseFieldsetInterceptor()(ctx, nil, info, handler); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if names := setAttributes(attributesMessage(req)); !reflect.DeepEqual(names, []string{"label"}) {
+		t.Fatalf("unexpected attributes %v", names)
+	}
+}
@@ -0,0 +1,57 @@
+package aphgrpc
+
+import (
+	"github.com/dictyBase/apihelpers/aphfilter"
+)
+
+// FilterExprToWhereClause generates a postgresql compatible where clause
+// along with the bind values from the filter expression. The grouping of the
+// expression is kept by parenthesizing every nested group. The clause is
+// empty for an empty or nil expression.
+func FilterExprToWhereClause(s JSONAPIParamsInfo, expr *aphfilter.Expr) (string, []interface{}, error) {
+	if expr.IsEmpty() {
+		return "", nil, nil
+	}
+	clause, values, err := aphfilter.ToPostgres(s.FilterToColumns(), expr)
+	if err != nil {
+		return "", values, err
+	}
+	return "WHERE " + clause, values, nil
+}
+
+// toAPIFilters converts the filters of the expression tree to APIFilter. It
+// is empty for a grouped expression, as the list of filters would lose its
+// grouping.
+func toAPIFilters(expr *aphfilter.Expr) []*APIFilter {
+	var filters []*APIFilter
+	if expr.IsGrouped() {
+		return filters
+	}
+	for _, f := range expr.Leaves() {
+		filters = append(filters, &APIFilter{
+			Attribute:  f.Field,
+			Operator:   string(f.Operator),
+			Expression: f.Value,
+			Logic:      f.Logic,
+			Quoted:     f.Quoted,
+			Values:     f.Values,
+		})
+	}
+	return filters
+}
+
+// toFilterExpr converts the list of APIFilter to an expression tree
+func toFilterExpr(filters []*APIFilter) *aphfilter.Expr {
+	var fl []*aphfilter.Filter
+	for _, f := range filters {
+		fl = append(fl, &aphfilter.Filter{
+			Field:    f.Attribute,
+			Operator: aphfilter.Operator(f.Operator),
+			Value:    f.Expression,
+			Values:   f.Values,
+			Quoted:   f.Quoted,
+			Logic:    f.Logic,
+		})
+	}
+	return aphfilter.FromList(fl)
+}
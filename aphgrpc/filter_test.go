@@ -0,0 +1,57 @@
+package aphgrpc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dictyBase/apihelpers/aphfilter"
+)
+
+func TestFilterExprToWhereClause(t *testing.T) {
+	s := &Service{FilToColumns: map[string]string{"name": "strain.name"}}
+	for _, expr := range []*aphfilter.Expr{nil, aphfilter.FromList(nil)} {
+		clause, values, err := FilterExprToWhereClause(s, expr)
+		if err != nil || len(clause) != 0 || len(values) != 0 {
+			t.Fatalf("expected empty clause, got %s %v %v", clause, values, err)
+		}
+	}
+	expr, err := aphfilter.Parse("name==sadA")
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	clause, values, err := FilterExprToWhereClause(s, expr)
+	if err != nil {
+		t.Fatalf("error in generating clause %s", err)
+	}
+	if clause != "WHERE strain.name = $1" || !reflect.DeepEqual(values, []interface{}{"sadA"}) {
+		t.Fatalf("unexpected clause %s %v", clause, values)
+	}
+}
+
+func TestParseFilterParamGrouped(t *testing.T) {
+	s := &Service{FilToColumns: map[string]string{"a": "a", "b": "b", "c": "c"}}
+	params := &JSONAPIParams{}
+	if err := parseFilterParam(s, params, "(a==1,b==2);c==3"); err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	if len(params.Filters) != 0 {
+		t.Fatalf("expected no list of filters for grouped filter, got %d", len(params.Filters))
+	}
+	clause, _, err := FilterExprToWhereClause(s, params.FilterExpr)
+	if err != nil {
+		t.Fatalf("error in generating clause %s", err)
+	}
+	if clause != "WHERE (a = $1 OR b = $2) AND c = $3" {
+		t.Fatalf("unexpected clause %s", clause)
+	}
+	params = &JSONAPIParams{}
+	if err := parseFilterParam(s, params, "a==1,b==2;c==3"); err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	if len(params.Filters) != 3 {
+		t.Fatalf("expected 3 filters, got %d", len(params.Filters))
+	}
+	if clause := FilterToWhereClause(s, params.Filters); clause != "WHERE a = $1 OR (b = $2 AND c = $3)" {
+		t.Fatalf("unexpected clause %s", clause)
+	}
+}
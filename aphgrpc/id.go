@@ -0,0 +1,43 @@
+package aphgrpc
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// FormatID converts a resource identifier to its path segment in a link. The
+// identifier could be any integer, a string such as an arangodb _key, or any
+// type that implements fmt.Stringer such as an UUID. The string
+// representation is escaped so that it is always a single path segment.
+func FormatID(id interface{}) string {
+	return url.PathEscape(idString(id))
+}
+
+// idString returns the unescaped string representation of an identifier
+func idString(id interface{}) string {
+	var v string
+	switch i := id.(type) {
+	case string:
+		v = i
+	case int64:
+		v = strconv.FormatInt(i, 10)
+	case int:
+		v = strconv.Itoa(i)
+	case int32:
+		v = strconv.FormatInt(int64(i), 10)
+	case uint64:
+		v = strconv.FormatUint(i, 10)
+	case uint32:
+		v = strconv.FormatUint(uint64(i), 10)
+	case uint:
+		v = strconv.FormatUint(uint64(i), 10)
+	case []byte:
+		v = string(i)
+	case fmt.Stringer:
+		v = i.String()
+	default:
+		v = fmt.Sprint(i)
+	}
+	return v
+}
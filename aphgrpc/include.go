@@ -0,0 +1,230 @@
+package aphgrpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// BatchLoader loads the related resources of a relationship for all the
+// given ids in a single call
+type BatchLoader func(ctx context.Context, ids []string) ([]proto.Message, error)
+
+// IncludeRegistry keeps the batch loaders of the relationships that could be
+// included in a compound document. It is safe for concurrent use.
+type IncludeRegistry struct {
+	mu      sync.RWMutex
+	loaders map[string]BatchLoader
+}
+
+// NewIncludeRegistry is the constructor for IncludeRegistry
+func NewIncludeRegistry() *IncludeRegistry {
+	return &IncludeRegistry{loaders: make(map[string]BatchLoader)}
+}
+
+// Register registers the batch loader of a relationship
+func (r *IncludeRegistry) Register(relation string, loader BatchLoader) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loaders[relation] = loader
+}
+
+// Relations returns the relationships that have a batch loader, they are
+// meant to be the allowed include parameters of the service
+func (r *IncludeRegistry) Relations() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var rels []string
+	for k := range r.loaders {
+		rels = append(rels, k)
+	}
+	sort.Strings(rels)
+	return rels
+}
+
+// Loader returns the batch loader of a relationship
+func (r *IncludeRegistry) Loader(relation string) (BatchLoader, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	l, ok := r.loaders[relation]
+	return l, ok
+}
+
+// Include fills the included section of the JSON API response with the
+// related resources of the given relationships. The ids of every
+// relationship are gathered from all the resources of the response, so that
+// its batch loader is called only once. The response is expected to have the
+// data field with a single or a list of resource objects and the repeated
+// included field of either google.protobuf.Any or the type of the loaded
+// messages. The relationships without any registered loader are skipped, they
+// are left to be included by the handler. The errors of the loaders are
+// wrapped, so that their cause is kept for HandleGetError.
+func (r *IncludeRegistry) Include(ctx context.Context, resp proto.Message, relations []string) error {
+	pm := proto.MessageReflect(resp)
+	incfd := pm.Descriptor().Fields().ByName("included")
+	if incfd == nil || !incfd.IsList() || incfd.Message() == nil {
+		return fmt.Errorf("response %s does not have any included field", pm.Descriptor().FullName())
+	}
+	for _, rel := range relations {
+		loader, ok := r.Loader(rel)
+		if !ok {
+			continue
+		}
+		ids := relationshipIDs(pm, rel)
+		if len(ids) == 0 {
+			continue
+		}
+		msgs, err := loader(ctx, ids)
+		if err != nil {
+			return errors.Wrapf(err, "unable to load relationship %s", rel)
+		}
+		if err := appendIncluded(pm, incfd, msgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IncludeResolverInfo is implemented by services that resolve the included
+// resources through a registry
+type IncludeResolverInfo interface {
+	IncludeResolver() *IncludeRegistry
+}
+
+// IncludeResolverOption sets the registry of the included resources
+func IncludeResolverOption(r *IncludeRegistry) Option {
+	return func(so *ServiceOptions) {
+		so.IncludeLoaders = r
+	}
+}
+
+// IncludeResolver returns the registry of the included resources
+func (s *Service) IncludeResolver() *IncludeRegistry {
+	return s.IncludeLoaders
+}
+
+// IncludeInterceptor returns a unary server interceptor that fills the
+// included section of the response for the include parameter of the request.
+// It is applied to the services that implement IncludeResolverInfo, and it
+// reads the parameters parsed by JSONAPIParamsInterceptor, so it has to be
+// chained after that one.
+func IncludeInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		ir, ok := info.Server.(IncludeResolverInfo)
+		if !ok || ir.IncludeResolver() == nil {
+			return resp, err
+		}
+		ri := RequestInfoFromContext(ctx)
+		if !ri.HasParams() || !ri.Params.HasInclude {
+			return resp, err
+		}
+		m, ok := resp.(proto.Message)
+		if !ok {
+			return resp, err
+		}
+		if err := ir.IncludeResolver().Include(ctx, m, ri.Params.Includes); err != nil {
+			return nil, HandleGetError(ctx, err)
+		}
+		return resp, nil
+	}
+}
+
+// relationshipIDs gathers the unique ids of a relationship from all the
+// resource objects of the response
+func relationshipIDs(m protoreflect.Message, relation string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, res := range resourceObjects(m) {
+		for _, id := range resourceRelationshipIDs(res, relation) {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// resourceObjects returns the resource objects in the data field
+func resourceObjects(m protoreflect.Message) []protoreflect.Message {
+	fd := m.Descriptor().Fields().ByName("data")
+	if fd == nil || fd.Message() == nil || fd.IsMap() || !m.Has(fd) {
+		return nil
+	}
+	if !fd.IsList() {
+		return []protoreflect.Message{m.Get(fd).Message()}
+	}
+	var res []protoreflect.Message
+	l := m.Get(fd).List()
+	for i := 0; i < l.Len(); i++ {
+		res = append(res, l.Get(i).Message())
+	}
+	return res
+}
+
+// resourceRelationshipIDs returns the ids of the resource linkage of a
+// relationship, which is at relationships.<relation>.data
+func resourceRelationshipIDs(res protoreflect.Message, relation string) []string {
+	rels, ok := messageField(res, "relationships")
+	if !ok {
+		return nil
+	}
+	rel, ok := messageField(rels, relation)
+	if !ok {
+		return nil
+	}
+	var ids []string
+	for _, linkage := range resourceObjects(rel) {
+		fd := linkage.Descriptor().Fields().ByName("id")
+		if fd != nil && linkage.Has(fd) {
+			ids = append(ids, fmt.Sprint(linkage.Get(fd).Interface()))
+		}
+	}
+	return ids
+}
+
+// messageField returns the singular message field matched by either its
+// proto or JSON name
+func messageField(m protoreflect.Message, name string) (protoreflect.Message, bool) {
+	fd := lookupAttribute(m, name)
+	if fd == nil || fd.Message() == nil || fd.IsList() || fd.IsMap() || !m.Has(fd) {
+		return nil, false
+	}
+	return m.Get(fd).Message(), true
+}
+
+// appendIncluded appends the messages to the included field, they are
+// packed in google.protobuf.Any if the field is of that type
+func appendIncluded(m protoreflect.Message, fd protoreflect.FieldDescriptor, msgs []proto.Message) error {
+	l := m.Mutable(fd).List()
+	for _, msg := range msgs {
+		v := msg
+		if fd.Message().FullName() == "google.protobuf.Any" {
+			a, err := ptypes.MarshalAny(msg)
+			if err != nil {
+				return fmt.Errorf("unable to pack included resource %s", err)
+			}
+			v = a
+		}
+		pv := proto.MessageReflect(v)
+		if pv.Descriptor().FullName() != fd.Message().FullName() {
+			return fmt.Errorf(
+				"included resource %s does not match %s",
+				pv.Descriptor().FullName(), fd.Message().FullName(),
+			)
+		}
+		l.Append(protoreflect.ValueOfMessage(pv))
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package aphgrpc
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// includeProto describes a JSON API collection response of the shape
+// {data: [{type, id, relationships: {publications: {data: [{type, id}]}}}],
+// included: [...]}
+const includeProto = `
+name: "aphgrpc_include_test.proto"
+package: "aphgrpc.include"
+syntax: "proto3"
+message_type: {
+	name: "Linkage"
+	field: {name: "type" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "type"}
+	field: {name: "id" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "id"}
+}
+message_type: {
+	name: "Relationship"
+	field: {name: "data" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".aphgrpc.include.Linkage" json_name: "data"}
+}
+message_type: {
+	name: "Relationships"
+	field: {name: "publications" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".aphgrpc.include.Relationship" json_name: "publications"}
+}
+message_type: {
+	name: "Resource"
+	field: {name: "type" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "type"}
+	field: {name: "id" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "id"}
+	field: {name: "relationships" number: 3 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".aphgrpc.include.Relationships" json_name: "relationships"}
+}
+message_type: {
+	name: "Response"
+	field: {name: "data" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".aphgrpc.include.Resource" json_name: "data"}
+	field: {name: "included" number: 2 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".aphgrpc.include.Resource" json_name: "included"}
+}
+`
+
+func includeMessages(t *testing.T) protoreflect.MessageDescriptors {
+	t.Helper()
+	fp := &descriptorpb.FileDescriptorProto{}
+	if err := prototext.Unmarshal([]byte(includeProto), fp); err != nil {
+		t.Fatalf("error in parsing test descriptor %s", err)
+	}
+	fd, err := protodesc.NewFile(fp, nil)
+	if err != nil {
+		t.Fatalf("error in building test descriptor %s", err)
+	}
+	return fd.Messages()
+}
+
+// includeResponse creates a response with a resource for every list of
+// publication ids, an empty id is a linkage without any id
+func includeResponse(msgs protoreflect.MessageDescriptors, pubs ...[]string) *dynamicpb.Message {
+	resp := dynamicpb.NewMessage(msgs.ByName("Response"))
+	data := resp.Mutable(resp.Descriptor().Fields().ByName("data")).List()
+	for _, ids := range pubs {
+		res := dynamicpb.NewMessage(msgs.ByName("Resource"))
+		setField(res, "type", protoreflect.ValueOfString("strains"))
+		rel := dynamicpb.NewMessage(msgs.ByName("Relationship"))
+		linkages := rel.Mutable(rel.Descriptor().Fields().ByName("data")).List()
+		for _, id := range ids {
+			l := dynamicpb.NewMessage(msgs.ByName("Linkage"))
+			setField(l, "type", protoreflect.ValueOfString("publications"))
+			if len(id) > 0 {
+				setField(l, "id", protoreflect.ValueOfString(id))
+			}
+			linkages.Append(protoreflect.ValueOfMessage(l))
+		}
+		rels := dynamicpb.NewMessage(msgs.ByName("Relationships"))
+		setField(rels, "publications", protoreflect.ValueOfMessage(rel))
+		setField(res, "relationships", protoreflect.ValueOfMessage(rels))
+		data.Append(protoreflect.ValueOfMessage(res))
+	}
+	return resp
+}
+
+// publicationLoader loads a resource for every id and records the ids of
+// every call
+func publicationLoader(msgs protoreflect.MessageDescriptors, calls *[][]string) BatchLoader {
+	return func(ctx context.Context, ids []string) ([]proto.Message, error) {
+		*calls = append(*calls, ids)
+		var res []proto.Message
+		for _, id := range ids {
+			m := dynamicpb.NewMessage(msgs.ByName("Resource"))
+			setField(m, "type", protoreflect.ValueOfString("publications"))
+			setField(m, "id", protoreflect.ValueOfString(id))
+			res = append(res, m)
+		}
+		return res, nil
+	}
+}
+
+func includedIDs(resp protoreflect.Message) []string {
+	var ids []string
+	l := resp.Get(resp.Descriptor().Fields().ByName("included")).List()
+	for i := 0; i < l.Len(); i++ {
+		m := l.Get(i).Message()
+		ids = append(ids, m.Get(m.Descriptor().Fields().ByName("id")).String())
+	}
+	return ids
+}
+
+func TestInclude(t *testing.T) {
+	var calls [][]string
+	msgs := includeMessages(t)
+	r := NewIncludeRegistry()
+	r.Register("publications", publicationLoader(msgs, &calls))
+	resp := includeResponse(msgs, []string{"1", "2"}, []string{"2", "3"})
+	if err := r.Include(context.Background(), resp, []string{"publications"}); err != nil {
+		t.Fatalf("error in including resources %s", err)
+	}
+	if !reflect.DeepEqual(calls, [][]string{{"1", "2", "3"}}) {
+		t.Fatalf("expected a single call of the loader with unique ids, got %v", calls)
+	}
+	if ids := includedIDs(resp); !reflect.DeepEqual(ids, []string{"1", "2", "3"}) {
+		t.Fatalf("unexpected included resources %v", ids)
+	}
+}
+
+func TestIncludeMissingIDs(t *testing.T) {
+	var calls [][]string
+	msgs := includeMessages(t)
+	r := NewIncludeRegistry()
+	r.Register("publications", publicationLoader(msgs, &calls))
+	resp := includeResponse(msgs, []string{""}, nil)
+	// authors has no loader and is left to the handler
+	if err := r.Include(context.Background(), resp, []string{"publications", "authors"}); err != nil {
+		t.Fatalf("error in including resources %s", err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no call of the loader, got %v", calls)
+	}
+	if ids := includedIDs(resp); len(ids) != 0 {
+		t.Fatalf("expected no included resource, got %v", ids)
+	}
+}
+
+func TestIncludeLoaderError(t *testing.T) {
+	r := NewIncludeRegistry()
+	r.Register("publications", func(ctx context.Context, ids []string) ([]proto.Message, error) {
+		return nil, errors.Wrap(sql.ErrNoRows, "publications")
+	})
+	err := r.Include(context.Background(), includeResponse(includeMessages(t), []string{"1"}), []string{"publications"})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected the loader error to be kept, got %v", err)
+	}
+	if code := status.Code(HandleGetError(context.Background(), err)); code != codes.NotFound {
+		t.Fatalf("expected not found status, got %s", code)
+	}
+}
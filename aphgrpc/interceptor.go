@@ -0,0 +1,75 @@
+package aphgrpc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/api/jsonapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// JSONAPIParamsInterceptor returns a unary server interceptor that validates
+// and parses the JSON API query parameters of ListRequest, SimpleListRequest
+// and GetRequest messages. The parameters are validated against the
+// JSONAPIParamsInfo implementation of the service that handles the call, any
+// other service or request is passed through untouched. The sort parameter,
+// passed on by the gateway through SortMetadata, is validated against the
+// SortParamsInfo implementation of the service.
+//
+// On success the handler receives the request context with the parsed
+// parameters and the base url resolved for the request, which are retrieved
+// by RequestInfoFromContext. On failure the error
+// trailer is set and the call is rejected with an InvalidArgument error,
+// that carries the invalid parameter as BadRequest details, without reaching
+// the handler.
+//
+// The attributes of the create and update requests, recognized from the
+// Create and Update prefixes of the method name, are validated against the
+// required attributes of the service and the rules of AttributeRulesInfo.
+// Every failing attribute is reported as a field violation of an
+// InvalidArgument error.
+func JSONAPIParamsInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if br, ok := info.Server.(BaseURLResolver); ok {
+			ctx = WithBaseURL(ctx, br.ResolveBaseURL(ctx))
+		} else if fctx, err := WithForwardedBaseURL(ctx); err == nil {
+			ctx = fctx
+		}
+		if rs, ok := info.Server.(JSONAPIResource); ok {
+			ctx = WithResource(ctx, rs.GetResourceName(), "")
+		}
+		jsapi, ok := info.Server.(JSONAPIParamsInfo)
+		if !ok {
+			return handler(ctx, req)
+		}
+		var params *JSONAPIParams
+		var md metadata.MD
+		var err error
+		switch r := req.(type) {
+		case *jsonapi.ListRequest:
+			params, md, err = ValidateAndParseListParamsCtx(ctx, jsapi, r)
+			if err == nil {
+				ctx = WithListReqCtx(ctx, params, r)
+			}
+		case *jsonapi.SimpleListRequest:
+			params, md, err = ValidateAndParseSimpleListParamsCtx(ctx, jsapi, r)
+			if err == nil {
+				ctx = WithSimpleListReqCtx(ctx, params, r)
+			}
+		case *jsonapi.GetRequest:
+			params, md, err = ValidateAndParseGetParams(jsapi, r)
+			if err == nil {
+				ctx = WithGetReqCtx(ctx, params, r)
+				ctx = context.WithValue(ctx, ContextKeyResourceID, strconv.FormatInt(r.Id, 10))
+			}
+		}
+		if err != nil {
+			return nil, HandleParamError(ctx, md, err)
+		}
+		if err := validateRequestAttributes(ctx, jsapi, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
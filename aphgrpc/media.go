@@ -0,0 +1,96 @@
+package aphgrpc
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/dictyBase/apihelpers/aphcollection"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Reasons of the ErrorInfo details for a failed media type negotiation
+const (
+	ReasonNotAcceptable    = "NOT_ACCEPTABLE"
+	ReasonUnsupportedMedia = "UNSUPPORTED_MEDIA_TYPE"
+)
+
+// JSONAPIMediaTypeHandler is an HTTP middleware for the grpc-gateway mux that
+// enforces the JSON API rules of media type negotiation. The ext and profile
+// are the only media type parameters that are allowed, the ext parameter is
+// further restricted to the given extension URIs.
+//
+// A request with a JSON API Content-Type having any other parameter or an
+// unsupported extension is rejected with 415 Unsupported Media Type. A request
+// whose Accept header has JSON API media types, all of which have any other
+// parameter or an unsupported extension, is rejected with 406 Not
+// Acceptable. Both are answered with a JSON API error document.
+//
+// for more information see https://jsonapi.org/format/#content-negotiation
+func JSONAPIMediaTypeHandler(next http.Handler, exts ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); len(ct) > 0 {
+			mt, params, err := mime.ParseMediaType(ct)
+			if err == nil && mt == JSONAPIMediaType {
+				if err := validMediaParams(params, exts); err != nil {
+					mediaTypeError(w, r, ErrUnsupportedMedia, ReasonUnsupportedMedia, err)
+					return
+				}
+			}
+		}
+		if err := acceptsJSONAPI(strings.Join(r.Header["Accept"], ","), exts); err != nil {
+			mediaTypeError(w, r, ErrNotAcceptable, ReasonNotAcceptable, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acceptsJSONAPI returns an error if all the JSON API media types in the
+// Accept header have invalid parameters. It is fine for the header to not
+// have any of them.
+func acceptsJSONAPI(accept string, exts []string) error {
+	var err error
+	for _, mr := range strings.Split(accept, ",") {
+		mt, params, perr := mime.ParseMediaType(strings.TrimSpace(mr))
+		if perr != nil || mt != JSONAPIMediaType {
+			continue
+		}
+		// the quality value is a parameter of the Accept header not of
+		// the media type
+		delete(params, "q")
+		if err = validMediaParams(params, exts); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// validMediaParams validates the parameters of the JSON API media type
+func validMediaParams(params map[string]string, exts []string) error {
+	for k, v := range params {
+		switch k {
+		case "profile":
+			// profiles could be ignored by the server
+		case "ext":
+			for _, e := range strings.Fields(v) {
+				if !aphcollection.Contains(exts, e) {
+					return fmt.Errorf("extension %s is not supported", e)
+				}
+			}
+		default:
+			return fmt.Errorf("media type parameter %s is not allowed", k)
+		}
+	}
+	return nil
+}
+
+// mediaTypeError writes the JSON API error document for a failed media type
+// negotiation, the reason decides its status, see httpStatus
+func mediaTypeError(w http.ResponseWriter, r *http.Request, md metadata.MD, reason string, err error) {
+	s := withDetails(r.Context(), status.New(codes.InvalidArgument, err.Error()), reason)
+	JSONAPIError(w, md, s)
+}
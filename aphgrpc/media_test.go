@@ -0,0 +1,54 @@
+package aphgrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestJSONAPIMediaTypeHandler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := JSONAPIMediaTypeHandler(next, "https://jsonapi.org/ext/atomic")
+	cases := []struct {
+		contentType, accept string
+		status              int
+		code                string
+	}{
+		{JSONAPIMediaType, JSONAPIMediaType, http.StatusOK, ""},
+		{JSONAPIMediaType + `; ext="https://jsonapi.org/ext/atomic"`, "*/*", http.StatusOK, ""},
+		{JSONAPIMediaType + "; charset=utf-8", JSONAPIMediaType, http.StatusUnsupportedMediaType, ReasonUnsupportedMedia},
+		{JSONAPIMediaType, JSONAPIMediaType + "; version=1", http.StatusNotAcceptable, ReasonNotAcceptable},
+		{JSONAPIMediaType, JSONAPIMediaType + "; version=1, " + JSONAPIMediaType, http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "http://localhost/strains", nil)
+		r.Header.Set("Content-Type", c.contentType)
+		r.Header.Set("Accept", c.accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Fatalf("expected status %d for %s %s, got %d", c.status, c.contentType, c.accept, w.Code)
+		}
+		if c.status == http.StatusOK {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != JSONAPIMediaType {
+			t.Fatalf("expected JSON API content type, got %s", ct)
+		}
+		var doc HTTPError
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("error in decoding error document %s", err)
+		}
+		if len(doc.Errors) != 1 {
+			t.Fatalf("expected a single error object, got %v", doc.Errors)
+		}
+		e := doc.Errors[0]
+		if e.Code != c.code || e.Status != strconv.Itoa(c.status) || len(e.ID) == 0 {
+			t.Fatalf("unexpected error object %+v", e)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package aphgrpc
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// JSONAPIMediaType is the media type of JSON API documents
+	JSONAPIMediaType = "application/vnd.api+json"
+	// ProblemMediaType is the media type of RFC 7807 problem documents
+	ProblemMediaType = "application/problem+json"
+)
+
+// Problem is an RFC 7807 problem document, the members after the instance
+// are extensions that carry the gRPC error details.
+//
+// for more information see https://tools.ietf.org/html/rfc7807
+type Problem struct {
+	Type          string          `json:"type"`
+	Title         string          `json:"title"`
+	Status        int             `json:"status"`
+	Detail        string          `json:"detail,omitempty"`
+	Instance      string          `json:"instance,omitempty"`
+	Code          string          `json:"code,omitempty"`
+	ID            string          `json:"id,omitempty"`
+	ResourceType  string          `json:"resource_type,omitempty"`
+	ResourceName  string          `json:"resource_name,omitempty"`
+	InvalidParams []*InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// InvalidParam describes a field violation in a problem document. The name
+// is either a query parameter or a JSON pointer to the request document.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ProblemError generates RFC 7807 formatted error message from the same
+// status and details that are used for JSONAPIError
+func ProblemError(w http.ResponseWriter, r *http.Request, s *status.Status) {
+	w.Header().Set("Content-Type", ProblemMediaType)
+	w.WriteHeader(httpStatus(s))
+	encErr := json.NewEncoder(w).Encode(newProblem(r, s))
+	if encErr != nil {
+		http.Error(w, encErr.Error(), http.StatusInternalServerError)
+	}
+}
+
+func newProblem(r *http.Request, s *status.Status) *Problem {
+	code := httpStatus(s)
+	p := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: s.Message(),
+	}
+	if r != nil && r.URL != nil {
+		p.Instance = r.URL.Path
+	}
+	for _, d := range s.Details() {
+		switch dt := d.(type) {
+		case *errdetails.ErrorInfo:
+			p.Code = dt.Reason
+			p.ID = dt.Metadata["id"]
+		case *errdetails.ResourceInfo:
+			p.ResourceType = dt.ResourceType
+			p.ResourceName = dt.ResourceName
+		case *errdetails.BadRequest:
+			for _, v := range dt.FieldViolations {
+				p.InvalidParams = append(p.InvalidParams, &InvalidParam{
+					Name:   violationName(v),
+					Reason: v.Description,
+				})
+			}
+		}
+	}
+	return p
+}
+
+// violationName is the query parameter or the JSON pointer of the violation
+func violationName(v *errdetails.BadRequest_FieldViolation) string {
+	src := violationSource(v.Field)
+	switch {
+	case src == nil:
+		return ""
+	case len(src.Parameter) > 0:
+		return src.Parameter
+	}
+	return src.Pointer
+}
+
+// AcceptsProblem reports whether the client prefers a problem document over
+// a JSON API error document. The JSON API document is preferred unless the
+// Accept header of the request ranks the problem media type higher.
+func AcceptsProblem(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	accept := strings.Join(r.Header["Accept"], ",")
+	return mediaQuality(accept, ProblemMediaType) > mediaQuality(accept, JSONAPIMediaType)
+}
+
+// mediaQuality returns the quality value of the media type in the Accept
+// header, the most specific matching media range decides the value. It is
+// zero if the media type is not acceptable.
+func mediaQuality(accept, mediaType string) float64 {
+	mtype := strings.SplitN(mediaType, "/", 2)[0]
+	quality, specificity := 0.0, -1
+	for _, mr := range strings.Split(accept, ",") {
+		rng, params, err := mime.ParseMediaType(strings.TrimSpace(mr))
+		if err != nil {
+			continue
+		}
+		var spec int
+		switch rng {
+		case mediaType:
+			spec = 2
+		case mtype + "/*":
+			spec = 1
+		case "*/*":
+			spec = 0
+		default:
+			continue
+		}
+		if spec <= specificity {
+			continue
+		}
+		q := 1.0
+		if qv, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qv, 64); err != nil {
+				q = 0
+			}
+		}
+		quality, specificity = q, spec
+	}
+	return quality
+}
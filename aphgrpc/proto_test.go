@@ -0,0 +1,109 @@
+package aphgrpc
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// testProto describes a JSON API request of the shape
+// {data: {type, id, attributes: {...}}, update_mask} along with a collection
+// of the shape {data: [{type, id, attributes: {...}}]}
+const testProto = `
+name: "aphgrpc_test.proto"
+package: "aphgrpc.test"
+syntax: "proto3"
+dependency: "google/protobuf/timestamp.proto"
+dependency: "google/protobuf/wrappers.proto"
+dependency: "google/protobuf/field_mask.proto"
+message_type: {
+	name: "Attrs"
+	field: {name: "name" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "name"}
+	field: {name: "count" number: 2 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "count" oneof_index: 0 proto3_optional: true}
+	field: {name: "updated_at" number: 3 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Timestamp" json_name: "updatedAt"}
+	field: {name: "label" number: 4 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.StringValue" json_name: "label"}
+	field: {name: "tags" number: 5 label: LABEL_REPEATED type: TYPE_STRING json_name: "tags"}
+	field: {name: "level" number: 6 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "level"}
+	field: {name: "active" number: 7 label: LABEL_OPTIONAL type: TYPE_BOOL json_name: "active"}
+	field: {name: "parent" number: 8 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".aphgrpc.test.Parent" json_name: "parent"}
+	oneof_decl: {name: "_count"}
+}
+message_type: {
+	name: "Parent"
+	field: {name: "name" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "name"}
+}
+message_type: {
+	name: "Data"
+	field: {name: "type" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "type"}
+	field: {name: "id" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "id"}
+	field: {name: "attributes" number: 3 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".aphgrpc.test.Attrs" json_name: "attributes"}
+}
+message_type: {
+	name: "Coll"
+	field: {name: "data" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".aphgrpc.test.Data" json_name: "data"}
+}
+message_type: {
+	name: "Req"
+	field: {name: "data" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".aphgrpc.test.Data" json_name: "data"}
+	field: {name: "update_mask" number: 2 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.FieldMask" json_name: "updateMask"}
+}
+`
+
+// testFile builds the descriptors of testProto
+func testFile(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	fp := &descriptorpb.FileDescriptorProto{}
+	if err := prototext.Unmarshal([]byte(testProto), fp); err != nil {
+		t.Fatalf("error in parsing test descriptor %s", err)
+	}
+	fd, err := protodesc.NewFile(fp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("error in building test descriptor %s", err)
+	}
+	return fd
+}
+
+// testRequest creates a request whose attributes are set by the given
+// function
+func testRequest(t *testing.T, set func(attrs protoreflect.Message)) *dynamicpb.Message {
+	t.Helper()
+	msgs := testFile(t).Messages()
+	req := dynamicpb.NewMessage(msgs.ByName("Req"))
+	data := dynamicpb.NewMessage(msgs.ByName("Data"))
+	attrs := dynamicpb.NewMessage(msgs.ByName("Attrs"))
+	setField(data, "type", protoreflect.ValueOfString("strains"))
+	if set != nil {
+		set(attrs)
+	}
+	setField(data, "attributes", protoreflect.ValueOfMessage(attrs))
+	setField(req, "data", protoreflect.ValueOfMessage(data))
+	return req
+}
+
+func setField(m protoreflect.Message, name string, v protoreflect.Value) {
+	m.Set(m.Descriptor().Fields().ByName(protoreflect.Name(name)), v)
+}
+
+func setTags(m protoreflect.Message, tags ...string) {
+	l := m.Mutable(m.Descriptor().Fields().ByName("tags")).List()
+	for _, tag := range tags {
+		l.Append(protoreflect.ValueOfString(tag))
+	}
+}
+
+func timestampValue(t time.Time) protoreflect.Value {
+	return protoreflect.ValueOfMessage(timestamppb.New(t).ProtoReflect())
+}
+
+func stringValue(s string) protoreflect.Value {
+	return protoreflect.ValueOfMessage(wrapperspb.String(s).ProtoReflect())
+}
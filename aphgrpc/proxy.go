@@ -0,0 +1,189 @@
+package aphgrpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ProxyPolicy decides whether the proxy headers of a request are trusted for
+// resolving its base url. The zero value trusts no proxy, in which case only
+// the host header is used.
+type ProxyPolicy struct {
+	// TrustAll trusts the proxy headers from every client
+	TrustAll bool
+	// Networks of the trusted proxies
+	Networks []*net.IPNet
+}
+
+// NewProxyPolicy creates a policy that trusts the proxies from the given
+// networks in CIDR notation, for example 10.0.0.0/8
+func NewProxyPolicy(cidrs ...string) (*ProxyPolicy, error) {
+	p := &ProxyPolicy{}
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return p, fmt.Errorf("invalid proxy network %s %s", c, err)
+		}
+		p.Networks = append(p.Networks, n)
+	}
+	return p, nil
+}
+
+// Trusts reports whether the proxy at the given address is trusted
+func (p *ProxyPolicy) Trusts(addr string) bool {
+	if p == nil {
+		return false
+	}
+	if p.TrustAll {
+		return true
+	}
+	ip := net.ParseIP(strings.TrimSpace(addr))
+	if ip == nil {
+		return false
+	}
+	for _, n := range p.Networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveBaseURL resolves the base url of the request from the proxy headers
+// of the incoming metadata. The RFC 7239 Forwarded header takes precedence
+// over x-forwarded-proto and x-forwarded-host, whereas x-forwarded-port and
+// x-forwarded-prefix complete the url. The headers are honoured only when
+// the request comes through trusted proxies, see TrustsRequest, otherwise
+// only the host is taken from x-forwarded-host. The scheme and host missing from the headers are taken
+// from the fallback url, which is returned as it is in the absence of any
+// metadata.
+func (p *ProxyPolicy) ResolveBaseURL(ctx context.Context, fallback string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return fallback
+	}
+	u := &url.URL{Scheme: "http"}
+	if fu, err := url.Parse(fallback); err == nil && len(fu.Scheme) > 0 {
+		u.Scheme, u.Host, u.Path = fu.Scheme, fu.Host, fu.Path
+	}
+	if host := firstMDValue(md, "x-forwarded-host"); len(host) > 0 {
+		u.Host = host
+	}
+	if !p.TrustsRequest(ctx) {
+		if len(u.Host) == 0 {
+			return fallback
+		}
+		return strings.TrimSuffix(u.String(), "/")
+	}
+	if proto := firstMDValue(md, "x-forwarded-proto"); len(proto) > 0 {
+		u.Scheme = strings.ToLower(proto)
+	}
+	fwd := parseForwarded(firstMDValue(md, "forwarded"))
+	if proto, ok := fwd["proto"]; ok {
+		u.Scheme = strings.ToLower(proto)
+	}
+	if host, ok := fwd["host"]; ok {
+		u.Host = host
+	}
+	if port := firstMDValue(md, "x-forwarded-port"); len(port) > 0 {
+		u.Host = withPort(u.Host, port, u.Scheme)
+	}
+	if prefix := firstMDValue(md, "x-forwarded-prefix"); len(prefix) > 0 {
+		u.Path = "/" + strings.Trim(prefix, "/")
+	}
+	if len(u.Host) == 0 {
+		return fallback
+	}
+	return strings.TrimSuffix(u.String(), "/")
+}
+
+// ProxyHeaderMatcher is a grpc-gateway incoming header matcher that forwards
+// the proxy headers to the gRPC metadata, every other header is matched by
+// the default matcher of grpc-gateway. It is used with the
+// runtime.WithIncomingHeaderMatcher option.
+func ProxyHeaderMatcher(key string) (string, bool) {
+	switch k := strings.ToLower(key); k {
+	case "forwarded", "x-forwarded-proto", "x-forwarded-port", "x-forwarded-prefix":
+		return k, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// TrustsRequest reports whether the proxy headers of the request are set by
+// trusted proxies. The gRPC peer, usually the gateway, has to be a trusted
+// proxy. The last entry of its x-forwarded-for header, which is the client
+// of the gateway, has to be a trusted proxy as well, otherwise the headers
+// could have been sent by the client itself. The x-forwarded-for header is
+// never looked up for an untrusted peer as it could be spoofed.
+func (p *ProxyPolicy) TrustsRequest(ctx context.Context) bool {
+	if !p.Trusts(peerAddr(ctx)) {
+		return false
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return true
+	}
+	xff, ok := md["x-forwarded-for"]
+	if !ok || len(xff) == 0 {
+		return true
+	}
+	hops := strings.Split(xff[len(xff)-1], ",")
+	return p.Trusts(hops[len(hops)-1])
+}
+
+// peerAddr returns the address of the gRPC peer, empty if it is unknown
+func peerAddr(ctx context.Context) string {
+	pr, ok := peer.FromContext(ctx)
+	if !ok || pr.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(pr.Addr.String())
+	if err != nil {
+		return pr.Addr.String()
+	}
+	return host
+}
+
+// firstMDValue returns the first value of a metadata key, for comma
+// separated values added by a chain of proxies that is the one closest to
+// the client
+func firstMDValue(md metadata.MD, key string) string {
+	v, ok := md[key]
+	if !ok || len(v) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(strings.Split(v[0], ",")[0])
+}
+
+// parseForwarded parses the first element of the RFC 7239 Forwarded header
+// to a map of lower cased parameter names and their unquoted values
+func parseForwarded(value string) map[string]string {
+	params := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+	return params
+}
+
+// withPort adds the port to the host unless it has one or it is the default
+// port of the scheme
+func withPort(host, port, scheme string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
@@ -0,0 +1,81 @@
+package aphgrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func proxyContext(peerIP string, kv ...string) context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+	if len(peerIP) == 0 {
+		return ctx
+	}
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(peerIP), Port: 40000}})
+}
+
+func TestResolveBaseURL(t *testing.T) {
+	p, err := NewProxyPolicy("10.0.0.0/8", "127.0.0.1/32")
+	if err != nil {
+		t.Fatalf("error in creating policy %s", err)
+	}
+	headers := []string{
+		"x-forwarded-proto", "https",
+		"x-forwarded-host", "api.dictybase.org",
+		"x-forwarded-prefix", "/stock",
+	}
+	fallback := "http://localhost:9595"
+	cases := []struct {
+		name string
+		ctx  context.Context
+		base string
+	}{
+		{
+			"trusted gateway and load balancer",
+			proxyContext("127.0.0.1", append(headers, "x-forwarded-for", "203.0.113.9, 10.1.1.1")...),
+			"https://api.dictybase.org/stock",
+		},
+		{
+			"trusted gateway without forwarded for",
+			proxyContext("127.0.0.1", headers...),
+			"https://api.dictybase.org/stock",
+		},
+		{
+			"client connected directly to the gateway",
+			proxyContext("127.0.0.1", append(headers, "x-forwarded-for", "203.0.113.9")...),
+			"http://api.dictybase.org",
+		},
+		{
+			"untrusted peer spoofing forwarded for",
+			proxyContext("203.0.113.9", append(headers, "x-forwarded-for", "10.1.1.1")...),
+			"http://api.dictybase.org",
+		},
+		{
+			"unknown peer",
+			proxyContext("", append(headers, "x-forwarded-for", "10.1.1.1")...),
+			"http://api.dictybase.org",
+		},
+		{
+			"no metadata",
+			context.Background(),
+			fallback,
+		},
+	}
+	for _, c := range cases {
+		if base := p.ResolveBaseURL(c.ctx, fallback); base != c.base {
+			t.Fatalf("%s: expected base url %s, got %s", c.name, c.base, base)
+		}
+	}
+	all := &ProxyPolicy{TrustAll: true}
+	ctx := proxyContext("203.0.113.9", append(headers, "x-forwarded-for", "198.51.100.1")...)
+	if base := all.ResolveBaseURL(ctx, fallback); base != "https://api.dictybase.org/stock" {
+		t.Fatalf("expected trusted base url, got %s", base)
+	}
+	var none *ProxyPolicy
+	if none.TrustsRequest(proxyContext("127.0.0.1")) {
+		t.Fatal("expected nil policy to trust no request")
+	}
+}
@@ -0,0 +1,112 @@
+package aphgrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// ContextKeyBaseURL is the context key for the base url resolved for a request
+var ContextKeyBaseURL = contextKey("baseURL")
+
+// RequestInfo is the request scoped view of a gRPC call. It is derived from
+// the context of the call, so that a Service shared between concurrent calls
+// never holds any per request state.
+type RequestInfo struct {
+	// BaseURL resolved for the request, empty if it has not been resolved
+	BaseURL string
+	// IsList is true for list(collection) requests
+	IsList bool
+	// Params are the parsed JSON API parameters, nil if they are absent
+	Params *JSONAPIParams
+	// Include is the raw include query parameter
+	Include string
+	// Fields is the raw fields query parameter
+	Fields string
+	// Filter is the raw filter query parameter
+	Filter string
+	// Sort is the raw sort query parameter
+	Sort string
+}
+
+// RequestInfoFromContext builds the request view from the context
+func RequestInfoFromContext(ctx context.Context) *RequestInfo {
+	ri := &RequestInfo{}
+	ri.BaseURL, _ = ctx.Value(ContextKeyBaseURL).(string)
+	_, ri.IsList = ctx.Value(ContextKeyIsList).(string)
+	ri.Params, _ = ctx.Value(ContextKeyParams).(*JSONAPIParams)
+	ri.Include, _ = ctx.Value(ContextKeyInclude).(string)
+	ri.Fields, _ = ctx.Value(ContextKeyFields).(string)
+	ri.Filter, _ = ctx.Value(ContextKeyFilter).(string)
+	ri.Sort, _ = ctx.Value(ContextKeySort).(string)
+	return ri
+}
+
+// HasParams reports whether the request has parsed JSON API parameters
+func (ri *RequestInfo) HasParams() bool {
+	return ri.Params != nil
+}
+
+// WithBaseURL derives a context from the parent with the base url that is
+// used for generating links for the request
+func WithBaseURL(ctx context.Context, base string) context.Context {
+	return context.WithValue(ctx, ContextKeyBaseURL, base)
+}
+
+// WithForwardedBaseURL derives a context from the parent with the base url
+// resolved from the x-forwarded-host metadata of the incoming request
+func WithForwardedBaseURL(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, ErrRetrieveMetadata
+	}
+	if _, ok := md["x-forwarded-host"]; !ok {
+		return ctx, ErrXForwardedHost
+	}
+	var p *ProxyPolicy
+	return WithBaseURL(ctx, p.ResolveBaseURL(ctx, "")), nil
+}
+
+// BaseURLResolver is implemented by services that resolve the base url of
+// every request
+type BaseURLResolver interface {
+	ResolveBaseURL(context.Context) string
+}
+
+// ResolveBaseURL resolves the base url of the request from its proxy headers
+// according to the proxy policy of the service, the base url of the service
+// is used for anything that cannot be resolved
+func (s *Service) ResolveBaseURL(ctx context.Context) string {
+	return s.ProxyPolicy.ResolveBaseURL(ctx, s.BaseURL)
+}
+
+// requestResource overrides the base url of a JSONAPIResource for a single
+// request
+type requestResource struct {
+	JSONAPIResource
+	baseURL string
+}
+
+func (r *requestResource) GetBaseURL() string {
+	return r.baseURL
+}
+
+// ReqBaseURL returns the base url resolved for the request, it is resolved
+// from the proxy headers when the context does not carry one
+func (s *Service) ReqBaseURL(ctx context.Context) string {
+	if base, ok := ctx.Value(ContextKeyBaseURL).(string); ok && len(base) > 0 {
+		return base
+	}
+	return s.ResolveBaseURL(ctx)
+}
+
+// ReqResource returns the JSONAPIResource view of the service for the
+// request, its base url is the one resolved for the request
+func (s *Service) ReqResource(ctx context.Context) JSONAPIResource {
+	return &requestResource{JSONAPIResource: s, baseURL: s.ReqBaseURL(ctx)}
+}
+
+// IsListRequest reports whether the request is for a list(collection)
+func (s *Service) IsListRequest(ctx context.Context) bool {
+	return RequestInfoFromContext(ctx).IsList
+}
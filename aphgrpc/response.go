@@ -0,0 +1,170 @@
+package aphgrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Trailer keys of the response contract between the gRPC handlers and the
+// gateway, they are internal and never sent to the HTTP client
+const (
+	// StatusKey is the trailer key of the HTTP status of a successful response
+	StatusKey = "http-status"
+	// LocationKey is the trailer key of the Location header
+	LocationKey = "location"
+	// MethodKey is the legacy trailer key whose values are POST(201) and
+	// POST_NO_CONTENT(204)
+	MethodKey = "method"
+)
+
+// internalTrailers are removed from the trailers of the HTTP response
+var internalTrailers = []string{StatusKey, LocationKey, MethodKey, MetaKey}
+
+// ResponseMeta is the typed contract for the HTTP status and the Location
+// header of a successful response, it is passed to the gateway in the
+// trailers of the gRPC call
+type ResponseMeta struct {
+	// Status is one of 200, 201, 202 or 204, the zero value is 200
+	Status int
+	// Location is the url of the created resource or of the status monitor
+	// of an accepted job
+	Location string
+}
+
+// MD converts the response meta to gRPC metadata
+func (rm *ResponseMeta) MD() metadata.MD {
+	md := metadata.MD{}
+	if rm.Status != 0 {
+		md.Set(StatusKey, strconv.Itoa(rm.Status))
+	}
+	if len(rm.Location) > 0 {
+		md.Set(LocationKey, rm.Location)
+	}
+	return md
+}
+
+func (rm *ResponseMeta) validate() error {
+	switch rm.Status {
+	case 0, http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent:
+		return nil
+	}
+	return fmt.Errorf("http status %d is not supported for a successful response", rm.Status)
+}
+
+// SetResponseMeta sets the response meta in the trailers of the gRPC call
+func SetResponseMeta(ctx context.Context, rm *ResponseMeta) error {
+	if err := rm.validate(); err != nil {
+		return err
+	}
+	return grpc.SetTrailer(ctx, rm.MD())
+}
+
+// ResponseMetaFromMD reads the response meta from the trailers, the legacy
+// method trailer is used in the absence of the http-status trailer. It is
+// false if the trailers have neither of them.
+func ResponseMetaFromMD(md metadata.MD) (*ResponseMeta, bool) {
+	rm := &ResponseMeta{}
+	if v := md.Get(LocationKey); len(v) > 0 {
+		rm.Location = v[0]
+	}
+	if v := md.Get(StatusKey); len(v) > 0 {
+		status, err := strconv.Atoi(v[0])
+		if err != nil {
+			return rm, false
+		}
+		rm.Status = status
+		return rm, rm.validate() == nil
+	}
+	if v := md.Get(MethodKey); len(v) > 0 {
+		switch v[0] {
+		case "POST":
+			rm.Status = http.StatusCreated
+			return rm, true
+		case "POST_NO_CONTENT":
+			rm.Status = http.StatusNoContent
+			return rm, true
+		}
+	}
+	return rm, len(rm.Location) > 0
+}
+
+// SetCreated sets the 201 status along with the Location of the created
+// resource
+func (s *Service) SetCreated(ctx context.Context, id interface{}) error {
+	return SetResponseMeta(ctx, &ResponseMeta{
+		Status:   http.StatusCreated,
+		Location: GenSingleResourceLink(s.ReqResource(ctx), id),
+	})
+}
+
+// SetAccepted sets the 202 status for an asynchronous job, the location is
+// the url of its status monitor, which could be empty
+func (s *Service) SetAccepted(ctx context.Context, location string) error {
+	return SetResponseMeta(ctx, &ResponseMeta{
+		Status:   http.StatusAccepted,
+		Location: location,
+	})
+}
+
+// SetNoContent sets the 204 status
+func (s *Service) SetNoContent(ctx context.Context) error {
+	return SetResponseMeta(ctx, &ResponseMeta{Status: http.StatusNoContent})
+}
+
+// HandleResponse is a forward response option of the grpc gateway which adds
+// the JSON API header and sets the HTTP status and the Location header from
+// the response meta of the call, regardless of the HTTP method. The internal
+// trailers are stripped from the HTTP response.
+func HandleResponse(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	w.Header().Set("Content-Type", JSONAPIMediaType)
+	md, ok := runtime.ServerMetadataFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	rm, ok := ResponseMetaFromMD(md.TrailerMD)
+	stripInternalTrailers(w, md.TrailerMD)
+	if !ok {
+		return nil
+	}
+	if len(rm.Location) > 0 {
+		w.Header().Set("Location", rm.Location)
+	}
+	if rm.Status != 0 {
+		w.WriteHeader(rm.Status)
+	}
+	return nil
+}
+
+// stripInternalTrailers removes the internal keys from the trailers, which
+// are shared with the gateway, and from the trailers announced in the HTTP
+// header
+func stripInternalTrailers(w http.ResponseWriter, md metadata.MD) {
+	internal := make(map[string]bool)
+	for _, k := range internalTrailers {
+		delete(md, k)
+		internal[textproto.CanonicalMIMEHeaderKey(runtime.MetadataTrailerPrefix+k)] = true
+	}
+	announced := w.Header()["Trailer"]
+	if len(announced) == 0 {
+		return
+	}
+	var keep []string
+	for _, t := range announced {
+		if !internal[textproto.CanonicalMIMEHeaderKey(t)] {
+			keep = append(keep, t)
+		}
+	}
+	if len(keep) == 0 {
+		w.Header().Del("Trailer")
+		return
+	}
+	w.Header()["Trailer"] = keep
+}
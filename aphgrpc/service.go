@@ -5,18 +5,21 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"gopkg.in/mgutz/dat.v2/dat"
 	"gopkg.in/mgutz/dat.v2/sqlx-runner"
 
+	"github.com/dictyBase/apihelpers/aphconv"
+	"github.com/dictyBase/apihelpers/aphlink"
+	"github.com/dictyBase/apihelpers/pubsub"
+	"github.com/dictyBase/apihelpers/repository"
 	"github.com/dictyBase/go-genproto/dictybaseapis/api/jsonapi"
 	"github.com/fatih/structs"
 	"github.com/golang/protobuf/proto"
-	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/timestamp"
-	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -38,6 +41,7 @@ var (
 	ContextKeyFilter  = contextKey("filterStr")
 	ContextKeyFields  = contextKey("fieldsStr")
 	ContextKeyIsList  = contextKey("isListMethod")
+	ContextKeySort    = contextKey("sortStr")
 )
 
 // JSONAPIParamsInfo interface should be implement by all grpc-gateway services
@@ -66,38 +70,60 @@ type JSONAPIResource interface {
 	GetPathPrefix() string
 }
 
+// NullToTime converts the null time to a timestamp, the null value is
+// converted to nil and the out of range times are converted without any error
+//
+// Deprecated: use aphconv.ValueToTimestamp, which reports the conversion
+// errors
 func NullToTime(nt dat.NullTime) *timestamp.Timestamp {
-	var ts *timestamp.Timestamp
-	if nt.Valid {
-		ts, _ := ptypes.TimestampProto(nt.Time)
-		return ts
+	if !nt.Valid {
+		return nil
 	}
-	return ts
+	return TimestampProto(nt.Time)
 }
 
+// ProtoTimeStamp converts the timestamp to time, nil is converted to the unix
+// epoch and the invalid timestamps are converted without any error
+//
+// Deprecated: use aphconv.TimestampToTime, which reports the conversion
+// errors
 func ProtoTimeStamp(ts *timestamp.Timestamp) time.Time {
-	t, _ := ptypes.Timestamp(ts)
+	t, err := aphconv.TimestampToTime(ts)
+	if err != nil {
+		return time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).UTC()
+	}
 	return t
 }
 
+// TimestampProto converts the time to a timestamp, the out of range times
+// are converted without any error
+//
+// Deprecated: use aphconv.TimeToTimestamp, which reports the conversion
+// errors
 func TimestampProto(t time.Time) *timestamp.Timestamp {
-	ts, _ := ptypes.TimestampProto(t)
+	ts, err := aphconv.TimeToTimestamp(t)
+	if err != nil {
+		return &timestamp.Timestamp{Seconds: t.Unix(), Nanos: int32(t.Nanosecond())}
+	}
 	return ts
 }
 
+// NullToString converts the null string to a string, the null value is
+// converted to the empty string
+//
+// Deprecated: use aphconv.ValueToStringValue, which keeps the null value
 func NullToString(s dat.NullString) string {
-	if s.Valid {
-		return s.String
-	}
-	return ""
+	v, _ := aphconv.ValueToStringValue(s)
+	return v.GetValue()
 }
 
+// NullToInt64 converts the null integer to an integer, the null value is
+// converted to zero
+//
+// Deprecated: use aphconv.ValueToInt64Value, which keeps the null value
 func NullToInt64(i dat.NullInt64) int64 {
-	if i.Valid {
-		return i.Int64
-	}
-	var i64 int64
-	return i64
+	v, _ := aphconv.ValueToInt64Value(i)
+	return v.GetValue()
 }
 
 // GetTotalPageNum calculate total no of pages from total no. records and page size
@@ -133,11 +159,13 @@ func GenBaseLink(rs JSONAPIResource) string {
 	)
 }
 
-func GenSingleResourceLink(rs JSONAPIResource, id int64) string {
+// GenSingleResourceLink generates the link of a single resource, the id is
+// formatted with FormatID
+func GenSingleResourceLink(rs JSONAPIResource, id interface{}) string {
 	return fmt.Sprintf(
-		"%s/%d",
+		"%s/%s",
 		GenBaseLink(rs),
-		id,
+		FormatID(id),
 	)
 }
 
@@ -145,39 +173,46 @@ func GenMultiResourceLink(rs JSONAPIResource) string {
 	return GenBaseLink(rs)
 }
 
+// AppendPaginationParams adds the pagination query parameters to the url,
+// any existing query parameter of the url is kept
 func AppendPaginationParams(url string, pagenum, pagesize int64) string {
-	return fmt.Sprintf("%s?pagenum=%d&pagesize=%d", url, pagenum, pagesize)
+	return aphlink.New(url).
+		Page("pagenum", strconv.FormatInt(pagenum, 10)).
+		Page("pagesize", strconv.FormatInt(pagesize, 10)).
+		String()
 }
 
 func GenPaginatedResourceLink(rs JSONAPIResource, pagenum, pagesize int64) string {
-	return fmt.Sprintf(
-		"%s?pagenum=%d&pagesize=%d",
-		GenBaseLink(rs),
-		pagenum,
-		pagesize,
-	)
+	return AppendPaginationParams(GenBaseLink(rs), pagenum, pagesize)
 }
 
-func GenSelfRelationshipLink(rs JSONAPIResource, rel string, id int64) string {
+// GenSelfRelationshipLink generates the self link of a relationship, the id
+// is formatted with FormatID
+func GenSelfRelationshipLink(rs JSONAPIResource, rel string, id interface{}) string {
 	return fmt.Sprintf(
-		"%s/%d/relationships/%s",
+		"%s/%s/relationships/%s",
 		GenBaseLink(rs),
-		id,
+		FormatID(id),
 		rel,
 	)
 }
 
-func GenRelatedRelationshipLink(rs JSONAPIResource, rel string, id int64) string {
+// GenRelatedRelationshipLink generates the related link of a relationship,
+// the id is formatted with FormatID
+func GenRelatedRelationshipLink(rs JSONAPIResource, rel string, id interface{}) string {
 	return fmt.Sprintf(
-		"%s/%d/%s",
+		"%s/%s/%s",
 		GenBaseLink(rs),
-		id,
+		FormatID(id),
 		rel,
 	)
 }
 
 //GetDefinedTagsWithValue check for fields that are initialized and returns a map
 //with the tag and their values
+//
+// Deprecated: the zero values are never considered as initialized, use
+// NewChangeset and its Columns
 func GetDefinedTagsWithValue(i interface{}, key string) map[string]interface{} {
 	m := make(map[string]interface{})
 	s := structs.New(i)
@@ -191,6 +226,9 @@ func GetDefinedTagsWithValue(i interface{}, key string) map[string]interface{} {
 
 //GetDefinedTags check for fields that are initialized and returns a slice of
 //their matching tag values
+//
+// Deprecated: the zero values are never considered as initialized, use
+// NewChangeset and its Fields
 func GetDefinedTags(i interface{}, tag string) []string {
 	var v []string
 	s := structs.New(i)
@@ -204,21 +242,11 @@ func GetDefinedTags(i interface{}, tag string) []string {
 
 // HandleCreateResponse modifies the grpc gateway filter which adds the JSON API header and
 // modifies the http status response for POST request
+//
+// Deprecated: use HandleResponse, which also sets the Location header and
+// supports every status of the ResponseMeta
 func HandleCreateResponse(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
-	w.Header().Set("Content-Type", "application/vnd.api+json")
-	md, ok := runtime.ServerMetadataFromContext(ctx)
-	if ok {
-		trMD := md.TrailerMD
-		if _, ok := trMD["method"]; ok {
-			switch trMD["method"][0] {
-			case "POST":
-				w.WriteHeader(http.StatusCreated)
-			case "POST_NO_CONTENT":
-				w.WriteHeader(http.StatusNoContent)
-			}
-		}
-	}
-	return nil
+	return HandleResponse(ctx, w, resp)
 }
 
 // SkipHTTPLinks looks up the context for the presence of gprc metadata
@@ -236,34 +264,61 @@ func SkipHTTPLinks(ctx context.Context) bool {
 
 // ListReqCtx generate context data from list(collection) request
 func ListReqCtx(params *JSONAPIParams, r *jsonapi.ListRequest) context.Context {
-	ctx := context.WithValue(context.Background(), ContextKeyIsList, "yes")
+	return WithListReqCtx(context.Background(), params, r)
+}
+
+// GetReqCtx generate a context data from get request
+func GetReqCtx(params *JSONAPIParams, r *jsonapi.GetRequest) context.Context {
+	return WithGetReqCtx(context.Background(), params, r)
+}
+
+// WithListReqCtx derives a context from the parent with data from
+// list(collection) request
+func WithListReqCtx(ctx context.Context, params *JSONAPIParams, r *jsonapi.ListRequest) context.Context {
+	ctx = context.WithValue(ctx, ContextKeyIsList, "yes")
+	return withParamsCtx(ctx, params, r.Include, r.Fields, r.Filter, sortParam(params.Sorts))
+}
+
+// WithSimpleListReqCtx derives a context from the parent with data from
+// simple list(collection without pagination) request
+func WithSimpleListReqCtx(ctx context.Context, params *JSONAPIParams, r *jsonapi.SimpleListRequest) context.Context {
+	ctx = context.WithValue(ctx, ContextKeyIsList, "yes")
+	return withParamsCtx(ctx, params, r.Include, r.Fields, r.Filter, sortParam(params.Sorts))
+}
+
+// WithGetReqCtx derives a context from the parent with data from get request
+func WithGetReqCtx(ctx context.Context, params *JSONAPIParams, r *jsonapi.GetRequest) context.Context {
+	return withParamsCtx(ctx, params, r.Include, r.Fields, "", "")
+}
+
+func withParamsCtx(ctx context.Context, params *JSONAPIParams, include, fields, filter, sort string) context.Context {
 	ctx = context.WithValue(ctx, ContextKeyParams, params)
 	if params.HasInclude {
-		ctx = context.WithValue(ctx, ContextKeyInclude, r.Include)
+		ctx = context.WithValue(ctx, ContextKeyInclude, include)
 	}
 	if params.HasFields {
-		ctx = context.WithValue(ctx, ContextKeyFields, r.Fields)
+		ctx = context.WithValue(ctx, ContextKeyFields, fields)
 	}
 	if params.HasFilter {
-		ctx = context.WithValue(ctx, ContextKeyFilter, r.Filter)
+		ctx = context.WithValue(ctx, ContextKeyFilter, filter)
+	}
+	if params.HasSort {
+		ctx = context.WithValue(ctx, ContextKeySort, sort)
 	}
 	return ctx
 }
 
-// GetReqCtx generate a context data from get request
-func GetReqCtx(params *JSONAPIParams, r *jsonapi.GetRequest) context.Context {
-	ctx := context.WithValue(context.Background(), ContextKeyParams, params)
-	if params.HasInclude {
-		ctx = context.WithValue(ctx, ContextKeyInclude, r.Include)
-	}
-	if params.HasFields {
-		ctx = context.WithValue(ctx, ContextKeyFields, r.Fields)
-	}
-	return ctx
+// ParamsFromContext retrieves the parsed JSON API parameters from the context
+func ParamsFromContext(ctx context.Context) (*JSONAPIParams, bool) {
+	params, ok := ctx.Value(ContextKeyParams).(*JSONAPIParams)
+	return params, ok
 }
 
 // AssignFieldsToStructs copy fields value
 // between structure
+//
+// Deprecated: the zero values are never copied, use NewChangeset and its
+// Apply
 func AssignFieldsToStructs(from interface{}, to interface{}) {
 	toR := structs.New(to)
 	for _, f := range structs.New(from).Fields() {
@@ -282,18 +337,54 @@ type ServiceOptions struct {
 	Include         []string
 	FieldsToColumns map[string]string
 	FilToColumns    map[string]string
+	SortColumns     map[string]string
 	ReqAttrs        []string
 	Topics          map[string]string
+	CursorSortKey   string
+	CursorIDKey     string
+	CursorDesc      bool
+	ProxyPolicy     *ProxyPolicy
+	AttrRules       map[string]*AttributeRule
+	IncludeLoaders  *IncludeRegistry
+	Repository      repository.Repository
+	Publisher       pubsub.Publisher
 }
 
 type Option func(*ServiceOptions)
 
+// TopicsOption sets the topics of the mutation events keyed by their action,
+// see EmitEvent
 func TopicsOption(t map[string]string) Option {
 	return func(so *ServiceOptions) {
 		so.Topics = t
 	}
 }
 
+// CursorPaginationOption enables keyset pagination using the given sort and id
+// columns, desc denotes a collection sorted in descending order
+func CursorPaginationOption(sortCol, idCol string, desc bool) Option {
+	return func(so *ServiceOptions) {
+		so.CursorSortKey = sortCol
+		so.CursorIDKey = idCol
+		so.CursorDesc = desc
+	}
+}
+
+// ProxyPolicyOption sets the policy for trusting the proxy headers while
+// resolving the base url of a request
+func ProxyPolicyOption(p *ProxyPolicy) Option {
+	return func(so *ServiceOptions) {
+		so.ProxyPolicy = p
+	}
+}
+
+// RepositoryOption sets the storage of the resource
+func RepositoryOption(r repository.Repository) Option {
+	return func(so *ServiceOptions) {
+		so.Repository = r
+	}
+}
+
 func JSONAPIResourceOptions(prefix, resource, base string) Option {
 	return func(so *ServiceOptions) {
 		so.PathPrefix = prefix
@@ -320,6 +411,14 @@ func FilterMappingOptions(fmap map[string]string) Option {
 	}
 }
 
+// SortMappingOptions sets the mapping between sort attributes and storage
+// columns
+func SortMappingOptions(smap map[string]string) Option {
+	return func(so *ServiceOptions) {
+		so.SortColumns = smap
+	}
+}
+
 func FieldsMappingOptions(fmap map[string]string) Option {
 	return func(so *ServiceOptions) {
 		so.FieldsToColumns = fmap
@@ -340,16 +439,40 @@ type Service struct {
 	Resource        string
 	BaseURL         string
 	FilToColumns    map[string]string
-	ListMethod      bool
-	ReqAttrs        []string
-	Context         context.Context
-	Topics          map[string]string
+	SortColumns     map[string]string
+	// Deprecated: the mode of the request is kept in its context, see
+	// RequestInfo
+	ListMethod bool
+	ReqAttrs   []string
+	// Deprecated: the service is shared by concurrent requests, use the
+	// context of the call
+	Context        context.Context
+	Topics         map[string]string
+	CursorSortKey  string
+	CursorIDKey    string
+	CursorDesc     bool
+	ProxyPolicy    *ProxyPolicy
+	AttrRules      map[string]*AttributeRule
+	IncludeLoaders *IncludeRegistry
+	// Repository is the storage of the resource, the counts are queried
+	// through Dbh in its absence
+	Repository repository.Repository
+	// Publisher publishes the mutation events to the Topics
+	Publisher pubsub.Publisher
+}
+
+// IsCursorPagination reports whether the service uses keyset pagination
+func (s *Service) IsCursorPagination() bool {
+	return len(s.CursorSortKey) > 0 && len(s.CursorIDKey) > 0
 }
 
 func (s *Service) RequiredAttrs() []string {
 	return s.ReqAttrs
 }
 
+// IsListMethod reports the ListMethod field of the service.
+//
+// Deprecated: use IsListRequest that looks up the request context.
 func (s *Service) IsListMethod() bool {
 	return s.ListMethod
 }
@@ -366,7 +489,24 @@ func (s *Service) AllowedFilter() []string {
 	return f
 }
 
+func (s *Service) SortToColumns() map[string]string {
+	return s.SortColumns
+}
+
+func (s *Service) AllowedSort() []string {
+	var f []string
+	for k := range s.SortToColumns() {
+		f = append(f, k)
+	}
+	return f
+}
+
+// AllowedInclude returns the relationships that could be included, they
+// default to the relationships of the include registry
 func (s *Service) AllowedInclude() []string {
+	if len(s.Include) == 0 && s.IncludeLoaders != nil {
+		return s.IncludeLoaders.Relations()
+	}
 	return s.Include
 }
 
@@ -398,31 +538,57 @@ func (s *Service) MapFieldsToColumns(fields []string) []string {
 	return columns
 }
 
+// GetCount counts all the records of the table, the table is ignored if the
+// service has a repository
 func (s *Service) GetCount(ctx context.Context, table string) (int64, error) {
+	if s.Repository != nil {
+		return s.Repository.Count(ctx, nil)
+	}
 	var count int64
 	err := s.Dbh.Select("COUNT(*)").From(table).QueryScalar(&count)
 	return count, err
 }
 
+// GetAllFilteredCount counts the records of the table that match the filter
+// of the request, all the records are counted if the request has no filter.
+// The table is ignored if the service has a repository.
 func (s *Service) GetAllFilteredCount(ctx context.Context, table string) (int64, error) {
 	var count int64
 	params, ok := ctx.Value(ContextKeyParams).(*JSONAPIParams)
 	if !ok {
 		return count, fmt.Errorf("no params object found in context")
 	}
-	err := s.Dbh.Select("COUNT(*)").
+	if params.FilterExpr.IsEmpty() {
+		return s.GetCount(ctx, table)
+	}
+	if s.Repository != nil {
+		return s.Repository.Count(ctx, params.FilterExpr)
+	}
+	clause, values, err := FilterExprToWhereClause(s, params.FilterExpr)
+	if err != nil {
+		return count, err
+	}
+	err = s.Dbh.Select("COUNT(*)").
 		From(table).
-		Scope(
-			FilterToWhereClause(s, params.Filters),
-			FilterToBindValue(params.Filters)...,
-		).QueryScalar(&count)
+		Scope(clause, values...).
+		QueryScalar(&count)
 	return count, err
 }
 
-// GetRelatedPagination generates JSONAPI pagination links for relation resources
+// GetRelatedPagination generates JSONAPI pagination links for relation
+// resources using the base url of the service
+//
+// Deprecated: use GetRelatedPaginationCtx that uses the base url resolved
+// for the request.
 func (s *Service) GetRelatedPagination(id, record, pagenum, pagesize int64, relation string) (*jsonapi.PaginationLinks, int64) {
+	return s.GetRelatedPaginationCtx(context.Background(), id, record, pagenum, pagesize, relation)
+}
+
+// GetRelatedPaginationCtx generates JSONAPI pagination links for relation
+// resources, the id is formatted with FormatID
+func (s *Service) GetRelatedPaginationCtx(ctx context.Context, id interface{}, record, pagenum, pagesize int64, relation string) (*jsonapi.PaginationLinks, int64) {
 	pages := GetTotalPageNum(record, pagesize)
-	baseLink := s.GenCollResourceRelSelfLink(id, relation)
+	baseLink := s.GenCollResourceRelSelfLinkCtx(ctx, id, relation)
 	pageLinks := GenPaginatedLinks(baseLink, pages, pagenum, pagesize)
 	jsapiLinks := &jsonapi.PaginationLinks{
 		Self:  pageLinks["self"],
@@ -438,62 +604,11 @@ func (s *Service) GetRelatedPagination(id, record, pagenum, pagesize int64, rela
 	return jsapiLinks, pages
 }
 
-// GetPagination generates JSONAPI pagination links along with fields, include and filter query parameters
+// GetPagination generates JSONAPI pagination links along with fields, include, filter and sort query parameters
 func (s *Service) GetPagination(ctx context.Context, record, pagenum, pagesize int64) (*jsonapi.PaginationLinks, int64) {
 	pages := GetTotalPageNum(record, pagesize)
 	baseLink := s.GenCollResourceSelfLink(ctx)
 	pageLinks := GenPaginatedLinks(baseLink, pages, pagenum, pagesize)
-	pageType := []string{"self", "last", "first", "previous", "next"}
-	params, ok := ctx.Value(ContextKeyParams).(*JSONAPIParams)
-	includeStr, _ := ctx.Value(ContextKeyInclude).(string)
-	fieldsStr, _ := ctx.Value(ContextKeyFields).(string)
-	filterStr, _ := ctx.Value(ContextKeyFilter).(string)
-	if ok {
-		switch {
-		case params.HasFields && params.HasInclude && params.HasFilter:
-			for _, v := range pageType {
-				if _, ok := pageLinks[v]; ok {
-					pageLinks[v] += fmt.Sprintf("&fields=%s&include=%s&filter=%s", fieldsStr, includeStr, filterStr)
-				}
-			}
-		case params.HasFields && params.HasInclude:
-			for _, v := range pageType {
-				if _, ok := pageLinks[v]; ok {
-					pageLinks[v] += fmt.Sprintf("&fields=%s&include=%s", fieldsStr, includeStr)
-				}
-			}
-		case params.HasFields && params.HasFilter:
-			for _, v := range pageType {
-				if _, ok := pageLinks[v]; ok {
-					pageLinks[v] += fmt.Sprintf("&fields=%s&filter=%s", fieldsStr, filterStr)
-				}
-			}
-		case params.HasInclude && params.HasFilter:
-			for _, v := range pageType {
-				if _, ok := pageLinks[v]; ok {
-					pageLinks[v] += fmt.Sprintf("&include=%s&filter=%s", includeStr, filterStr)
-				}
-			}
-		case params.HasInclude:
-			for _, v := range pageType {
-				if _, ok := pageLinks[v]; ok {
-					pageLinks[v] += fmt.Sprintf("&include=%s", includeStr)
-				}
-			}
-		case params.HasFilter:
-			for _, v := range pageType {
-				if _, ok := pageLinks[v]; ok {
-					pageLinks[v] += fmt.Sprintf("&filter=%s", filterStr)
-				}
-			}
-		case params.HasFields:
-			for _, v := range pageType {
-				if _, ok := pageLinks[v]; ok {
-					pageLinks[v] += fmt.Sprintf("&fields=%s", fieldsStr)
-				}
-			}
-		}
-	}
 	jsapiLinks := &jsonapi.PaginationLinks{
 		Self:  pageLinks["self"],
 		Last:  pageLinks["last"],
@@ -508,69 +623,70 @@ func (s *Service) GetPagination(ctx context.Context, record, pagenum, pagesize i
 	return jsapiLinks, pages
 }
 
+// GenCollResourceRelSelfLink generates the link of the related resources
+// using the base url of the service
+//
+// Deprecated: use GenCollResourceRelSelfLinkCtx that uses the base url
+// resolved for the request.
 func (s *Service) GenCollResourceRelSelfLink(id int64, relation string) string {
-	return fmt.Sprintf(
-		"%s/%d/%s",
-		GenMultiResourceLink(s),
-		id,
-		relation,
-	)
+	return s.GenCollResourceRelSelfLinkCtx(context.Background(), id, relation)
+}
+
+// GenCollResourceRelSelfLinkCtx generates the link of the related resources,
+// the id is formatted with FormatID
+func (s *Service) GenCollResourceRelSelfLinkCtx(ctx context.Context, id interface{}, relation string) string {
+	return GenRelatedRelationshipLink(s.ReqResource(ctx), relation, id)
 }
 
+// GenCollResourceSelfLink generates the self link of a collection along with
+// the fields, include, filter and sort query parameters of the request
 func (s *Service) GenCollResourceSelfLink(ctx context.Context) string {
-	link := GenMultiResourceLink(s)
-	params, ok := ctx.Value(ContextKeyParams).(*JSONAPIParams)
-	if !ok {
-		return link
-	}
-	includeStr, _ := ctx.Value(ContextKeyInclude).(string)
-	fieldsStr, _ := ctx.Value(ContextKeyFields).(string)
-	filterStr, _ := ctx.Value(ContextKeyFilter).(string)
-	switch {
-	case params.HasFields && params.HasFilter && params.HasInclude:
-		link += fmt.Sprintf("?fields=%s&include=%s&filter=%s", fieldsStr, includeStr, filterStr)
-	case params.HasFields && params.HasFilter:
-		link += fmt.Sprintf("?fields=%s&filter=%s", fieldsStr, filterStr)
-	case params.HasFields && params.HasInclude:
-		link += fmt.Sprintf("?fields=%s&include=%s", fieldsStr, includeStr)
-	case params.HasFilter && params.HasInclude:
-		link += fmt.Sprintf("?filter=%s&include=%s", filterStr, includeStr)
-	case params.HasInclude:
-		link += fmt.Sprintf("?include=%s", includeStr)
-	case params.HasFilter:
-		link += fmt.Sprintf("?filter=%s", filterStr)
-	case params.HasFields:
-		link += fmt.Sprintf("?fields=%s", fieldsStr)
-	}
-	return link
-}
-
-func (s *Service) GenResourceSelfLink(ctx context.Context, id int64) string {
-	links := GenSingleResourceLink(s, id)
-	params, ok := ctx.Value(ContextKeyParams).(*JSONAPIParams)
-	_, lok := ctx.Value(ContextKeyIsList).(string)
-	if !lok && ok {
-		includeStr, _ := ctx.Value(ContextKeyInclude).(string)
-		fieldsStr, _ := ctx.Value(ContextKeyFields).(string)
-		switch {
-		case params.HasFields && params.HasInclude:
-			links += fmt.Sprintf("?fields=%s&include=%s", fieldsStr, includeStr)
-		case params.HasFields:
-			links += fmt.Sprintf("?fields=%s", fieldsStr)
-		case params.HasInclude:
-			links += fmt.Sprintf("?include=%s", includeStr)
+	link := aphlink.New(GenMultiResourceLink(s.ReqResource(ctx)))
+	ri := RequestInfoFromContext(ctx)
+	if !ri.HasParams() {
+		return link.String()
+	}
+	if ri.Params.HasFields {
+		link.Fields(ri.Fields)
+	}
+	if ri.Params.HasInclude {
+		link.Include(ri.Include)
+	}
+	if ri.Params.HasFilter {
+		link.Filter(ri.Filter)
+	}
+	if ri.Params.HasSort {
+		link.Sort(ri.Sort)
+	}
+	return link.String()
+}
+
+// GenResourceSelfLink generates the self link of a single resource along with
+// the fields and include query parameters of the request
+func (s *Service) GenResourceSelfLink(ctx context.Context, id interface{}) string {
+	link := aphlink.New(GenSingleResourceLink(s.ReqResource(ctx), id))
+	ri := RequestInfoFromContext(ctx)
+	if !ri.IsList && ri.HasParams() {
+		if ri.Params.HasFields {
+			link.Fields(ri.Fields)
+		}
+		if ri.Params.HasInclude {
+			link.Include(ri.Include)
 		}
 	}
-	return links
+	return link.String()
 }
 
+// SetBaseURL sets the base url of the service from the proxy metadata of the
+// Context field.
+//
+// Deprecated: it modifies the service that is shared by concurrent requests,
+// use ResolveBaseURL and WithBaseURL to keep the base url in the request
+// context.
 func (s *Service) SetBaseURL() error {
-	md, ok := metadata.FromIncomingContext(s.Context)
-	if !ok {
+	if _, ok := metadata.FromIncomingContext(s.Context); !ok {
 		return ErrRetrieveMetadata
 	}
-	if slice, ok := md["x-forwarded-host"]; ok {
-		s.BaseURL = fmt.Sprintf("http://%s", slice[0])
-	}
+	s.BaseURL = s.ResolveBaseURL(s.Context)
 	return nil
 }
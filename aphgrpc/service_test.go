@@ -0,0 +1,32 @@
+package aphgrpc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRelatedPagination(t *testing.T) {
+	s := &Service{Resource: "strains", BaseURL: "https://api.dictybase.org", PathPrefix: "strains"}
+	link := s.GenCollResourceRelSelfLink(5, "publications")
+	exlink := "https://api.dictybase.org/strains/5/publications"
+	if link != exlink {
+		t.Fatalf("expected link %s, got %s", exlink, link)
+	}
+	ctx := WithBaseURL(context.Background(), "https://stock.dictybase.org")
+	link = s.GenCollResourceRelSelfLinkCtx(ctx, "a b", "publications")
+	exlink = "https://stock.dictybase.org/strains/a%20b/publications"
+	if link != exlink {
+		t.Fatalf("expected link %s, got %s", exlink, link)
+	}
+	links, pages := s.GetRelatedPagination(5, 25, 2, 10, "publications")
+	if pages != 3 {
+		t.Fatalf("expected 3 pages, got %d", pages)
+	}
+	if links.Prev == "" || links.Next == "" || links.Last == "" {
+		t.Fatalf("expected previous, next and last links, got %v", links)
+	}
+	ctxLinks, _ := s.GetRelatedPaginationCtx(context.Background(), int64(5), 25, 2, 10, "publications")
+	if ctxLinks.Self != links.Self {
+		t.Fatalf("expected self link %s, got %s", links.Self, ctxLinks.Self)
+	}
+}
@@ -0,0 +1,129 @@
+package aphgrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dictyBase/apihelpers/aphcollection"
+	"google.golang.org/grpc/metadata"
+)
+
+// SortKey is the metadata key of the raw sort query parameter, the jsonapi
+// request messages have no sort field so it is passed on by the gateway
+// through SortMetadata
+const SortKey = "jsonapi-sort"
+
+// SortParamsInfo is implemented by services that support the JSON API sort
+// parameter
+type SortParamsInfo interface {
+	// Sort fields that are allowed
+	AllowedSort() []string
+	// SortToColumns provides mapping between sort and storage columns
+	SortToColumns() map[string]string
+}
+
+// SortField is a container for a single sort criterion
+type SortField struct {
+	// Attribute of the resource on which the sorting is applied
+	Attribute string
+	// Sort in descending order
+	Desc bool
+}
+
+// SortMetadata is a metadata annotator of the grpc gateway, registered with
+// runtime.WithMetadata, that passes on the sort query parameter of the HTTP
+// request to the gRPC handlers
+func SortMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	sortStr := r.URL.Query().Get("sort")
+	if len(sortStr) == 0 {
+		return nil
+	}
+	return metadata.Pairs(SortKey, sortStr)
+}
+
+// SortFromContext returns the raw sort parameter that is passed on by the
+// gateway, empty if the request is not sorted
+func SortFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md.Get(SortKey); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// ValidateAndParseSortParam validate and parse the JSON API sort parameter,
+// a comma separated list of attributes where a - prefix denotes descending
+// order, for example sort=-created_at,name
+func ValidateAndParseSortParam(jsapi SortParamsInfo, params *JSONAPIParams, sortStr string) error {
+	var sorts []*SortField
+	for _, v := range strings.Split(sortStr, ",") {
+		sf := &SortField{Attribute: v}
+		if strings.HasPrefix(v, "-") {
+			sf.Attribute = strings.TrimPrefix(v, "-")
+			sf.Desc = true
+		}
+		if len(sf.Attribute) == 0 {
+			return fmt.Errorf("empty sort attribute in %s", sortStr)
+		}
+		if !aphcollection.Contains(jsapi.AllowedSort(), sf.Attribute) {
+			return fmt.Errorf("%s sort attribute is not allowed", sf.Attribute)
+		}
+		sorts = append(sorts, sf)
+	}
+	params.HasSort = true
+	params.Sorts = sorts
+	return nil
+}
+
+// validateSortParam parses the sort parameter of the request context for the
+// services that support sorting
+func validateSortParam(ctx context.Context, server interface{}, params *JSONAPIParams) error {
+	sortStr := SortFromContext(ctx)
+	if len(sortStr) == 0 {
+		return nil
+	}
+	sp, ok := server.(SortParamsInfo)
+	if !ok {
+		return fmt.Errorf("sort parameter is not supported")
+	}
+	return ValidateAndParseSortParam(sp, params, sortStr)
+}
+
+// sortParam formats the sort fields back to the sort parameter
+func sortParam(sorts []*SortField) string {
+	var attrs []string
+	for _, sf := range sorts {
+		if sf.Desc {
+			attrs = append(attrs, "-"+sf.Attribute)
+		} else {
+			attrs = append(attrs, sf.Attribute)
+		}
+	}
+	return strings.Join(attrs, ",")
+}
+
+// SortToOrderBy generates a postgresql compatible order by expression from the
+// provided sort fields. Only the mapped storage columns are used in the
+// expression, so it is safe to be passed to the OrderBy method of dat runner.
+// It fails for any attribute without a mapping.
+func SortToOrderBy(s SortParamsInfo, sorts []*SortField) (string, error) {
+	smap := s.SortToColumns()
+	var order []string
+	for _, sf := range sorts {
+		col, ok := smap[sf.Attribute]
+		if !ok {
+			return "", fmt.Errorf("no mapping found for sort attribute %s", sf.Attribute)
+		}
+		if sf.Desc {
+			order = append(order, fmt.Sprintf("%s DESC", col))
+		} else {
+			order = append(order, fmt.Sprintf("%s ASC", col))
+		}
+	}
+	return strings.Join(order, ", "), nil
+}
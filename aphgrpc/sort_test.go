@@ -0,0 +1,115 @@
+package aphgrpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dictyBase/go-genproto/dictybaseapis/api/jsonapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// unsortedServer implements JSONAPIParamsInfo without SortParamsInfo
+type unsortedServer struct{}
+
+func (unsortedServer) AllowedInclude() []string           { return nil }
+func (unsortedServer) AllowedFields() []string            { return nil }
+func (unsortedServer) AllowedFilter() []string            { return nil }
+func (unsortedServer) FilterToColumns() map[string]string { return nil }
+func (unsortedServer) RequiredAttrs() []string            { return nil }
+
+func sortContext(r string) context.Context {
+	req := httptest.NewRequest("GET", "http://localhost/strains?"+r, nil)
+	return metadata.NewIncomingContext(context.Background(), SortMetadata(context.Background(), req))
+}
+
+func TestSortFromContext(t *testing.T) {
+	if s := SortFromContext(sortContext("sort=-created_at,name")); s != "-created_at,name" {
+		t.Fatalf("unexpected sort %s", s)
+	}
+	if s := SortFromContext(sortContext("filter=name==a")); len(s) != 0 {
+		t.Fatalf("expected empty sort, got %s", s)
+	}
+	if s := SortFromContext(context.Background()); len(s) != 0 {
+		t.Fatalf("expected empty sort, got %s", s)
+	}
+}
+
+func TestSortToOrderBy(t *testing.T) {
+	s := &Service{SortColumns: map[string]string{"name": "strain.name", "created_at": "strain.created_at"}}
+	params := &JSONAPIParams{}
+	if err := ValidateAndParseSortParam(s, params, "-created_at,name"); err != nil {
+		t.Fatalf("error in parsing sort %s", err)
+	}
+	if sp := sortParam(params.Sorts); sp != "-created_at,name" {
+		t.Fatalf("unexpected sort parameter %s", sp)
+	}
+	order, err := SortToOrderBy(s, params.Sorts)
+	if err != nil {
+		t.Fatalf("error in generating order by %s", err)
+	}
+	if order != "strain.created_at DESC, strain.name ASC" {
+		t.Fatalf("unexpected order by %s", order)
+	}
+	for _, sortStr := range []string{"label", "name,", "-"} {
+		if err := ValidateAndParseSortParam(s, &JSONAPIParams{}, sortStr); err == nil {
+			t.Fatalf("expected error for sort %s", sortStr)
+		}
+	}
+	if _, err := SortToOrderBy(s, []*SortField{{Attribute: "label"}}); err == nil {
+		t.Fatal("expected error for unmapped sort attribute")
+	}
+}
+
+func TestSortInterceptor(t *testing.T) {
+	var params *JSONAPIParams
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		params, _ = ParamsFromContext(ctx)
+		return nil, nil
+	}
+	ctx := sortContext("sort=-name")
+	info := &grpc.UnaryServerInfo{
+		Server:     &Service{SortColumns: map[string]string{"name": "name"}},
+		FullMethod: "/strain.StrainService/ListStrains",
+	}
+	if _, err := JSONAPIParamsInterceptor()(ctx, &jsonapi.ListRequest{}, info, handler); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if params == nil || !params.HasSort || len(params.Sorts) != 1 || !params.Sorts[0].Desc {
+		t.Fatalf("unexpected sort params %v", params)
+	}
+	info.Server = unsortedServer{}
+	_, err := JSONAPIParamsInterceptor()(ctx, &jsonapi.ListRequest{}, info, handler)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected invalid argument error, got %v", err)
+	}
+}
+
+func TestValidateAndParseListParamsCtx(t *testing.T) {
+	s := &Service{SortColumns: map[string]string{"name": "name", "created_at": "created_at"}}
+	params, _, err := ValidateAndParseListParamsCtx(sortContext("sort=name,-created_at"), s, &jsonapi.ListRequest{})
+	if err != nil {
+		t.Fatalf("error in parsing params %s", err)
+	}
+	if !params.HasSort || sortParam(params.Sorts) != "name,-created_at" {
+		t.Fatalf("unexpected sort params %v", params.Sorts)
+	}
+	params, _, err = ValidateAndParseSimpleListParamsCtx(sortContext("sort=name"), s, &jsonapi.SimpleListRequest{})
+	if err != nil {
+		t.Fatalf("error in parsing params %s", err)
+	}
+	if !params.HasSort || sortParam(params.Sorts) != "name" {
+		t.Fatalf("unexpected sort params %v", params.Sorts)
+	}
+	_, md, err := ValidateAndParseListParamsCtx(sortContext("sort=label"), s, &jsonapi.ListRequest{})
+	if err == nil || !reflect.DeepEqual(md, ErrSortParam) {
+		t.Fatalf("expected sort param error, got %v %v", md, err)
+	}
+	if _, _, err := ValidateAndParseListParamsCtx(sortContext("sort=name"), unsortedServer{}, &jsonapi.ListRequest{}); err == nil {
+		t.Fatal("expected error for a service without sort support")
+	}
+}
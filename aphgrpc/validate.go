@@ -1,18 +1,16 @@
 package aphgrpc
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"regexp"
 	"strings"
 
 	"github.com/dictyBase/apihelpers/aphcollection"
+	"github.com/dictyBase/apihelpers/aphfilter"
 	"github.com/dictyBase/go-genproto/dictybaseapis/api/jsonapi"
 	"google.golang.org/grpc/metadata"
 )
 
-var re = regexp.MustCompile(`(\w+)(\=\=|\!\=|\=\@|\!\@)(\w+)(\,|\;)?`)
-
 // JSONAPIParams is a container for various JSON API query parameters
 type JSONAPIParams struct {
 	// contain include query paramters
@@ -25,8 +23,16 @@ type JSONAPIParams struct {
 	HasInclude bool
 	// check for presence of filter parameters
 	HasFilter bool
-	// slice of filters
+	// slice of filters, it is empty for the filters with parenthesized
+	// groups that are only available from FilterExpr
 	Filters []*APIFilter
+	// expression tree of filters
+	FilterExpr *aphfilter.Expr
+	// check for presence of sort parameters, the sort parameter is parsed
+	// only by the ctx aware validators
+	HasSort bool
+	// slice of sort criteria in the order of precedence
+	Sorts []*SortField
 }
 
 // APIFilter is a container for filter parameters
@@ -39,55 +45,42 @@ type APIFilter struct {
 	Expression string
 	//
 	Logic string
+	// Whether the value was given as a quoted string
+	Quoted bool
+	// Values of an in or out list
+	Values []string
 }
 
-// FilterToBindValue generates a postgresql compatible query expression from
-// the given filters
+// FilterToBindValue generates postgresql compatible bind values from the
+// given filters. Null checks do not bind any value whereas the in lists bind
+// one value for every member.
+//
+// Deprecated: use FilterExprToWhereClause that generates both the clause and
+// the bind values from the expression tree.
 func FilterToBindValue(filters []*APIFilter) []interface{} {
-	values := make([]interface{}, len(filters))
-	for i, f := range filters {
-		expr := f.Expression
-		if strings.Contains(f.Operator, "@") {
-			expr = fmt.Sprintf(".*%s.*", expr)
-		}
-		values[i] = expr
+	// the column mapping is irrelevant for the bind values
+	cols := make(map[string]string)
+	for _, f := range filters {
+		cols[f.Attribute] = f.Attribute
 	}
+	_, values, _ := aphfilter.ToPostgres(cols, toFilterExpr(filters))
 	return values
 }
 
 // FilterToWhereClause generates a postgresql compatible where clause from the
 // provided filters
+//
+// Deprecated: use FilterExprToWhereClause that keeps the grouping of filters
+// and reports filters that cannot be rendered.
 func FilterToWhereClause(s JSONAPIParamsInfo, filters []*APIFilter) string {
-	lmap := map[string]string{",": "OR", ";": "AND"}
-	fmap := s.FilterToColumns()
-	omap := getOperatorMap()
-	clause := bytes.NewBufferString("WHERE ")
-	for i, f := range filters {
-		clause.WriteString(
-			fmt.Sprintf(
-				"%s %s $%d",
-				fmap[f.Attribute],
-				omap[f.Operator],
-				i+1,
-			),
-		)
-		if len(f.Logic) != 0 {
-			clause.WriteString(fmt.Sprintf(" %s", lmap[f.Logic]))
-		}
-	}
-	return clause.String()
+	clause, _, _ := FilterExprToWhereClause(s, toFilterExpr(filters))
+	return clause
 }
 
-func getOperatorMap() map[string]string {
-	return map[string]string{
-		"==": "=",
-		"!=": "!=",
-		"=@": "~*",
-		"!@": "!~*",
-	}
-}
-
-// ValidateAndParseSimpleListParams validate and parse the JSON API include, fields, filter parameters
+// ValidateAndParseSimpleListParams validate and parse the JSON API include,
+// fields and filter parameters. The sort parameter is not part of the request
+// message, it is parsed along with the others by
+// ValidateAndParseSimpleListParamsCtx.
 func ValidateAndParseSimpleListParams(jsapi JSONAPIParamsInfo, r *jsonapi.SimpleListRequest) (*JSONAPIParams, metadata.MD, error) {
 	params := &JSONAPIParams{
 		HasFields:  false,
@@ -122,31 +115,16 @@ func ValidateAndParseSimpleListParams(jsapi JSONAPIParamsInfo, r *jsonapi.Simple
 		params.HasFields = true
 	}
 	if len(r.Filter) != 0 {
-		m := re.FindAllStringSubmatch(r.Filter, -1)
-		if len(m) > 0 {
-			var filters []*APIFilter
-			for _, n := range m {
-				if !aphcollection.Contains(jsapi.AllowedFilter(), n[1]) {
-					return params, ErrFilterParam, fmt.Errorf("%s filter attribute is not allowed", n[1])
-				}
-				f := &APIFilter{
-					Attribute:  n[1],
-					Operator:   n[2],
-					Expression: n[3],
-				}
-				if len(n) == 5 {
-					f.Logic = n[4]
-				}
-				filters = append(filters, f)
-			}
-			params.HasFilter = true
-			params.Filters = filters
+		if err := parseFilterParam(jsapi, params, r.Filter); err != nil {
+			return params, ErrFilterParam, err
 		}
 	}
 	return params, metadata.Pairs("errors", "none"), nil
 }
 
-// ValidateAndParseListParams validate and parse the JSON API include, fields, filter parameters
+// ValidateAndParseListParams validate and parse the JSON API include, fields
+// and filter parameters. The sort parameter is not part of the request
+// message, it is parsed along with the others by ValidateAndParseListParamsCtx.
 func ValidateAndParseListParams(jsapi JSONAPIParamsInfo, r *jsonapi.ListRequest) (*JSONAPIParams, metadata.MD, error) {
 	params := &JSONAPIParams{
 		HasFields:  false,
@@ -181,30 +159,61 @@ func ValidateAndParseListParams(jsapi JSONAPIParamsInfo, r *jsonapi.ListRequest)
 		params.HasFields = true
 	}
 	if len(r.Filter) != 0 {
-		m := re.FindAllStringSubmatch(r.Filter, -1)
-		if len(m) > 0 {
-			var filters []*APIFilter
-			for _, n := range m {
-				if !aphcollection.Contains(jsapi.AllowedFilter(), n[1]) {
-					return params, ErrFilterParam, fmt.Errorf("%s filter attribute is not allowed", n[1])
-				}
-				f := &APIFilter{
-					Attribute:  n[1],
-					Operator:   n[2],
-					Expression: n[3],
-				}
-				if len(n) == 5 {
-					f.Logic = n[4]
-				}
-				filters = append(filters, f)
-			}
-			params.HasFilter = true
-			params.Filters = filters
+		if err := parseFilterParam(jsapi, params, r.Filter); err != nil {
+			return params, ErrFilterParam, err
 		}
 	}
 	return params, metadata.Pairs("errors", "none"), nil
 }
 
+// ValidateAndParseSimpleListParamsCtx validate and parse the JSON API
+// include, fields, filter and sort parameters. The sort parameter is read
+// from the request context, where it is passed on by the gateway through
+// SortMetadata, and is validated against the SortParamsInfo implementation
+// of jsapi.
+func ValidateAndParseSimpleListParamsCtx(ctx context.Context, jsapi JSONAPIParamsInfo, r *jsonapi.SimpleListRequest) (*JSONAPIParams, metadata.MD, error) {
+	params, md, err := ValidateAndParseSimpleListParams(jsapi, r)
+	if err != nil {
+		return params, md, err
+	}
+	if err := validateSortParam(ctx, jsapi, params); err != nil {
+		return params, ErrSortParam, err
+	}
+	return params, md, nil
+}
+
+// ValidateAndParseListParamsCtx validate and parse the JSON API include,
+// fields, filter and sort parameters, see ValidateAndParseSimpleListParamsCtx
+// for the sort parameter
+func ValidateAndParseListParamsCtx(ctx context.Context, jsapi JSONAPIParamsInfo, r *jsonapi.ListRequest) (*JSONAPIParams, metadata.MD, error) {
+	params, md, err := ValidateAndParseListParams(jsapi, r)
+	if err != nil {
+		return params, md, err
+	}
+	if err := validateSortParam(ctx, jsapi, params); err != nil {
+		return params, ErrSortParam, err
+	}
+	return params, md, nil
+}
+
+// parseFilterParam parses the filter string to an expression tree and
+// validates its attributes
+func parseFilterParam(jsapi JSONAPIParamsInfo, params *JSONAPIParams, fstr string) error {
+	expr, err := aphfilter.Parse(fstr)
+	if err != nil {
+		return err
+	}
+	for _, f := range expr.Leaves() {
+		if !aphcollection.Contains(jsapi.AllowedFilter(), f.Field) {
+			return fmt.Errorf("%s filter attribute is not allowed", f.Field)
+		}
+	}
+	params.HasFilter = true
+	params.Filters = toAPIFilters(expr)
+	params.FilterExpr = expr
+	return nil
+}
+
 // ValidateAndParseGetParams validate and parse the JSON API include and fields parameters
 // that are used for singular resources
 func ValidateAndParseGetParams(jsapi JSONAPIParamsInfo, r *jsonapi.GetRequest) (*JSONAPIParams, metadata.MD, error) {
@@ -0,0 +1,359 @@
+// Package aphhealth aggregates the health of the dependencies of a service,
+// which is exposed through the grpc.health.v1 service and the /healthz and
+// /readyz http endpoints.
+package aphhealth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dictyBase/apihelpers/pubsub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"gopkg.in/mgutz/dat.v2/sqlx-runner"
+)
+
+const (
+	// DefaultInterval is the default time between two rounds of checks
+	DefaultInterval = 10 * time.Second
+	// DefaultTimeout is the default time limit of every check
+	DefaultTimeout = 3 * time.Second
+	// LivenessPath is the http path of the liveness probe
+	LivenessPath = "/healthz"
+	// ReadinessPath is the http path of the readiness probe
+	ReadinessPath = "/readyz"
+)
+
+// Checker checks the health of a dependency
+type Checker interface {
+	// Check returns an error if the dependency is not usable
+	Check(context.Context) error
+}
+
+// CheckerFunc is an adapter to use an ordinary function as a Checker
+type CheckerFunc func(context.Context) error
+
+// Check calls f(ctx)
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// DBChecker checks the database connection of the dat handle
+func DBChecker(dbh *runner.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if dbh == nil || dbh.DB == nil {
+			return errors.New("database handle is not set")
+		}
+		return dbh.DB.PingContext(ctx)
+	})
+}
+
+// PubsubChecker checks the connection of a publisher, subscriber, request or
+// reply, it fails if the value does not implement pubsub.Checker
+func PubsubChecker(v interface{}) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		c, ok := v.(pubsub.Checker)
+		if !ok {
+			return fmt.Errorf("%T does not support health check", v)
+		}
+		return c.Check(ctx)
+	})
+}
+
+// Result is the outcome of the latest check of a dependency
+type Result struct {
+	// Error of the check, nil for a healthy dependency
+	Err error
+	// CheckedAt is the start time of the check
+	CheckedAt time.Time
+	// Duration of the check
+	Duration time.Duration
+}
+
+type Options struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	Services []string
+}
+
+type Option func(*Options)
+
+// IntervalOption sets the time between two rounds of checks
+func IntervalOption(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
+
+// TimeoutOption sets the time limit of every check
+func TimeoutOption(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
+// ServicesOption sets the names of the grpc services whose serving status
+// follows the aggregated status, the overall status of the server(empty
+// name) is always set
+func ServicesOption(names ...string) Option {
+	return func(o *Options) {
+		o.Services = names
+	}
+}
+
+// Health runs the registered checks in the background and keeps the
+// aggregated serving status. The status is SERVING only if every check has
+// passed in the latest round, it is NOT_SERVING till the first round is
+// complete. It is safe for concurrent use.
+type Health struct {
+	opts     *Options
+	server   *health.Server
+	mu       sync.RWMutex
+	checkers map[string]Checker
+	results  map[string]*Result
+	serving  bool
+}
+
+// NewHealth is the constructor for Health
+func NewHealth(options ...Option) *Health {
+	opts := &Options{Interval: DefaultInterval, Timeout: DefaultTimeout}
+	for _, optfn := range options {
+		optfn(opts)
+	}
+	h := &Health{
+		opts:     opts,
+		server:   health.NewServer(),
+		checkers: make(map[string]Checker),
+		results:  make(map[string]*Result),
+	}
+	h.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	return h
+}
+
+// Register registers the checker of a dependency
+func (h *Health) Register(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkers[name] = c
+}
+
+// RegisterFunc registers a function as the checker of a dependency
+func (h *Health) RegisterFunc(name string, fn func(context.Context) error) {
+	h.Register(name, CheckerFunc(fn))
+}
+
+// Server returns the grpc.health.v1 server
+func (h *Health) Server() healthpb.HealthServer {
+	return h.server
+}
+
+// RegisterGRPC registers the grpc.health.v1 service on the grpc server
+func (h *Health) RegisterGRPC(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, h.server)
+}
+
+// Start runs the checks immediately and then at every interval until the
+// context is done, after which the status is set to NOT_SERVING. It does
+// not block. No round is started once the context is done, as its checks
+// would only fail with the cancelled context.
+func (h *Health) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(h.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				h.shutdown()
+				return
+			default:
+			}
+			h.CheckAll(ctx)
+			select {
+			case <-ctx.Done():
+				h.shutdown()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// shutdown sets the status to NOT_SERVING for good
+func (h *Health) shutdown() {
+	h.mu.Lock()
+	h.serving = false
+	h.mu.Unlock()
+	h.server.Shutdown()
+}
+
+// CheckAll runs all the checks concurrently, each with its own timeout, and
+// updates the aggregated status. It returns whether every check has passed.
+func (h *Health) CheckAll(ctx context.Context) bool {
+	h.mu.RLock()
+	checkers := make(map[string]Checker, len(h.checkers))
+	for name, c := range h.checkers {
+		checkers[name] = c
+	}
+	h.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	var rmu sync.Mutex
+	results := make(map[string]*Result, len(checkers))
+	for name, c := range checkers {
+		wg.Add(1)
+		go func(name string, c Checker) {
+			defer wg.Done()
+			r := h.check(ctx, c)
+			rmu.Lock()
+			results[name] = r
+			rmu.Unlock()
+		}(name, c)
+	}
+	wg.Wait()
+
+	serving := true
+	for _, r := range results {
+		if r.Err != nil {
+			serving = false
+		}
+	}
+	h.mu.Lock()
+	h.results = results
+	h.serving = serving
+	h.mu.Unlock()
+	if serving {
+		h.setStatus(healthpb.HealthCheckResponse_SERVING)
+	} else {
+		h.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return serving
+}
+
+func (h *Health) check(ctx context.Context, c Checker) *Result {
+	ctx, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	defer cancel()
+	r := &Result{CheckedAt: time.Now()}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.Check(ctx)
+	}()
+	// the checkers that ignore the context are not waited for beyond the
+	// timeout
+	select {
+	case r.Err = <-errc:
+	case <-ctx.Done():
+		r.Err = fmt.Errorf("health check timed out %s", ctx.Err())
+	}
+	r.Duration = time.Since(r.CheckedAt)
+	return r
+}
+
+func (h *Health) setStatus(st healthpb.HealthCheckResponse_ServingStatus) {
+	h.server.SetServingStatus("", st)
+	for _, name := range h.opts.Services {
+		h.server.SetServingStatus(name, st)
+	}
+}
+
+// Serving reports the aggregated status of the latest round of checks
+func (h *Health) Serving() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.serving
+}
+
+// Results returns the results of the latest round of checks
+func (h *Health) Results() map[string]*Result {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	results := make(map[string]*Result, len(h.results))
+	for name, r := range h.results {
+		results[name] = r
+	}
+	return results
+}
+
+// LivenessHandler reports that the process is up, it does not depend on
+// the health of the dependencies
+func (h *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, &statusDoc{Status: "ok"})
+	})
+}
+
+// ReadinessHandler reports the aggregated status along with the result of
+// every check, the response is 503 if the service is not serving
+func (h *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := h.Results()
+		doc := &statusDoc{Status: "ok", Checks: make(map[string]*checkDoc)}
+		var names []string
+		for name := range results {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			res := results[name]
+			cd := &checkDoc{Status: "ok", Duration: res.Duration.String()}
+			if res.Err != nil {
+				cd.Status = "error"
+				cd.Error = res.Err.Error()
+			}
+			doc.Checks[name] = cd
+		}
+		code := http.StatusOK
+		if !h.Serving() {
+			doc.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+		writeStatus(w, code, doc)
+	})
+}
+
+// Handler serves the liveness and readiness probes, every other request is
+// passed on to the next handler, which is usually the gateway mux
+func (h *Health) Handler(next http.Handler) http.Handler {
+	live := h.LivenessHandler()
+	ready := h.ReadinessHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			switch r.URL.Path {
+			case LivenessPath:
+				live.ServeHTTP(w, r)
+				return
+			case ReadinessPath:
+				ready.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type statusDoc struct {
+	Status string               `json:"status"`
+	Checks map[string]*checkDoc `json:"checks,omitempty"`
+}
+
+type checkDoc struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+func writeStatus(w http.ResponseWriter, code int, doc *statusDoc) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	encErr := json.NewEncoder(w).Encode(doc)
+	if encErr != nil {
+		http.Error(w, encErr.Error(), http.StatusInternalServerError)
+	}
+}
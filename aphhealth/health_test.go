@@ -0,0 +1,127 @@
+package aphhealth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func servingStatus(t *testing.T, h *Health, service string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := h.Server().Check(
+		context.Background(),
+		&healthpb.HealthCheckRequest{Service: service},
+	)
+	if err != nil {
+		t.Fatalf("error in grpc health check %s", err)
+	}
+	return resp.Status
+}
+
+func readiness(t *testing.T, h *Health) (int, *statusDoc) {
+	notFound := http.NotFoundHandler()
+	w := httptest.NewRecorder()
+	h.Handler(notFound).ServeHTTP(w, httptest.NewRequest("GET", ReadinessPath, nil))
+	doc := &statusDoc{}
+	if err := json.NewDecoder(w.Body).Decode(doc); err != nil {
+		t.Fatalf("error in decoding readiness response %s", err)
+	}
+	return w.Code, doc
+}
+
+func TestCheckAll(t *testing.T) {
+	var dberr error
+	h := NewHealth(ServicesOption("stock"), TimeoutOption(50*time.Millisecond))
+	h.RegisterFunc("db", func(ctx context.Context) error { return dberr })
+	h.RegisterFunc("nats", func(ctx context.Context) error { return nil })
+	if servingStatus(t, h, "") != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatal("expected not serving status before the first check")
+	}
+	if !h.CheckAll(context.Background()) {
+		t.Fatal("expected all checks to pass")
+	}
+	for _, name := range []string{"", "stock"} {
+		if st := servingStatus(t, h, name); st != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("expected serving status for %q, got %s", name, st)
+		}
+	}
+	code, doc := readiness(t, h)
+	if code != http.StatusOK || doc.Status != "ok" || len(doc.Checks) != 2 {
+		t.Fatalf("unexpected readiness response %d %+v", code, doc)
+	}
+
+	dberr = errors.New("connection refused")
+	if h.CheckAll(context.Background()) {
+		t.Fatal("expected the database check to fail")
+	}
+	if st := servingStatus(t, h, "stock"); st != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expected not serving status, got %s", st)
+	}
+	code, doc = readiness(t, h)
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, code)
+	}
+	if doc.Checks["db"].Error != "connection refused" || doc.Checks["nats"].Status != "ok" {
+		t.Fatalf("unexpected checks %+v %+v", doc.Checks["db"], doc.Checks["nats"])
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	h := NewHealth(TimeoutOption(20 * time.Millisecond))
+	h.RegisterFunc("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	start := time.Now()
+	if h.CheckAll(context.Background()) {
+		t.Fatal("expected the slow check to fail")
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("expected the check to stop at the timeout")
+	}
+	if h.Results()["slow"].Err == nil {
+		t.Fatal("expected timeout error for the slow check")
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	h := NewHealth()
+	w := httptest.NewRecorder()
+	h.Handler(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", LivenessPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.Handler(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", "/stocks", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected the request to be passed on, got %d", w.Code)
+	}
+}
+
+func TestStartCancelled(t *testing.T) {
+	var calls int32
+	h := NewHealth(IntervalOption(5 * time.Millisecond))
+	h.RegisterFunc("db", func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("expected no check after cancellation, got %d", n)
+	}
+	if len(h.Results()) != 0 {
+		t.Fatalf("expected no check result, got %v", h.Results())
+	}
+	if st := servingStatus(t, h, ""); st != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expected not serving status, got %s", st)
+	}
+}
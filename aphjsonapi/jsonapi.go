@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"strconv"
 	"strings"
 
+	"github.com/dictyBase/apihelpers/aphlink"
 	"github.com/dictyBase/go-middlewares/middlewares/pagination"
 	jsapi "github.com/manyminds/api2go/jsonapi"
 )
@@ -164,12 +166,10 @@ func generateBaseLink(ep jsapi.ServerInformation) string {
 }
 
 func generatePaginatedResourceLink(baseurl string, pagenum, pagesize int) string {
-	return fmt.Sprintf(
-		"%s?page[number]=%d&page[size]=%d",
-		baseurl,
-		pagenum,
-		pagesize,
-	)
+	return aphlink.New(baseurl).
+		Page("page[number]", strconv.Itoa(pagenum)).
+		Page("page[size]", strconv.Itoa(pagesize)).
+		String()
 }
 
 func generateSingleResourceLink(jdata *jsapi.Data, ep jsapi.ServerInformation) string {
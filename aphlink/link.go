@@ -0,0 +1,195 @@
+// Package aphlink provides a builder for the query string of the links that
+// are generated for JSON API responses.
+package aphlink
+
+import (
+	"net/url"
+	"strings"
+)
+
+// The keys of the JSON API query parameters, they are always written in this
+// order before the paging and any other parameters
+const (
+	Fields  = "fields"
+	Include = "include"
+	Filter  = "filter"
+	Sort    = "sort"
+)
+
+var jsonapiKeys = []string{Fields, Include, Filter, Sort}
+
+// param is a single query parameter
+type param struct {
+	key   string
+	value string
+}
+
+// Builder builds a link from a base url and query parameters. The parameters
+// are written in a fixed order, first the JSON API parameters(fields,
+// include, filter and sort), then the paging parameters and at last any other
+// parameter in the order they were added. Every key and value is percent
+// encoded, except the brackets of the keys(page[number]) and the commas
+// separating the values of a list.
+type Builder struct {
+	base   string
+	params map[string]string
+	paging []*param
+	extra  []*param
+}
+
+// New creates a builder for the link, the query parameters of the link are
+// kept and placed in their canonical position
+func New(link string) *Builder {
+	b := &Builder{base: link, params: make(map[string]string)}
+	idx := strings.Index(link, "?")
+	if idx == -1 {
+		return b
+	}
+	b.base = link[:idx]
+	for _, pair := range strings.Split(link[idx+1:], "&") {
+		if len(pair) == 0 {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		key, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			key = kv[0]
+		}
+		var value string
+		if len(kv) == 2 {
+			if value, err = url.QueryUnescape(kv[1]); err != nil {
+				value = kv[1]
+			}
+		}
+		b.Set(key, value)
+	}
+	return b
+}
+
+// Fields sets the fields parameter
+func (b *Builder) Fields(value string) *Builder {
+	return b.Set(Fields, value)
+}
+
+// Include sets the include parameter
+func (b *Builder) Include(value string) *Builder {
+	return b.Set(Include, value)
+}
+
+// Filter sets the filter parameter
+func (b *Builder) Filter(value string) *Builder {
+	return b.Set(Filter, value)
+}
+
+// Sort sets the sort parameter
+func (b *Builder) Sort(value string) *Builder {
+	return b.Set(Sort, value)
+}
+
+// Page sets a paging parameter, for example pagenum or page[size]. The paging
+// parameters are written after the JSON API parameters in the order they were
+// first set.
+func (b *Builder) Page(key, value string) *Builder {
+	b.paging = setParam(b.paging, key, value)
+	return b
+}
+
+// Set sets any query parameter, overwriting any existing value. An empty
+// value removes the parameter.
+func (b *Builder) Set(key, value string) *Builder {
+	switch {
+	case isJSONAPIKey(key):
+		if len(value) == 0 {
+			delete(b.params, key)
+		} else {
+			b.params[key] = value
+		}
+	case isPagingKey(key):
+		b.paging = setParam(b.paging, key, value)
+	default:
+		b.extra = setParam(b.extra, key, value)
+	}
+	return b
+}
+
+// Clone returns a copy of the builder, so that links that differ only by few
+// parameters(paging for example) can be built from a common one
+func (b *Builder) Clone() *Builder {
+	c := &Builder{
+		base:   b.base,
+		params: make(map[string]string),
+		paging: append([]*param{}, b.paging...),
+		extra:  append([]*param{}, b.extra...),
+	}
+	for k, v := range b.params {
+		c.params[k] = v
+	}
+	return c
+}
+
+// Query returns the encoded query string without the leading question mark
+func (b *Builder) Query() string {
+	var qs []string
+	for _, k := range jsonapiKeys {
+		if v, ok := b.params[k]; ok {
+			qs = append(qs, encode(k, v))
+		}
+	}
+	for _, p := range b.paging {
+		qs = append(qs, encode(p.key, p.value))
+	}
+	for _, p := range b.extra {
+		qs = append(qs, encode(p.key, p.value))
+	}
+	return strings.Join(qs, "&")
+}
+
+// String returns the link with its query string
+func (b *Builder) String() string {
+	qs := b.Query()
+	if len(qs) == 0 {
+		return b.base
+	}
+	return b.base + "?" + qs
+}
+
+// setParam replaces the value of an existing parameter or appends a new one
+// to the list, an empty value removes the parameter
+func setParam(params []*param, key, value string) []*param {
+	for i, p := range params {
+		if p.key != key {
+			continue
+		}
+		if len(value) == 0 {
+			return append(params[:i:i], params[i+1:]...)
+		}
+		params[i] = &param{key: key, value: value}
+		return params
+	}
+	if len(value) == 0 {
+		return params
+	}
+	return append(params, &param{key: key, value: value})
+}
+
+func isJSONAPIKey(key string) bool {
+	for _, k := range jsonapiKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isPagingKey matches the page based(pagenum, pagesize), the JSON API
+// page[...] family and the cursor parameters
+func isPagingKey(key string) bool {
+	return key == "pagenum" || key == "pagesize" || strings.HasPrefix(key, "page[")
+}
+
+// encode percent encodes the key and value of a parameter
+func encode(key, value string) string {
+	k := strings.NewReplacer("%5B", "[", "%5D", "]").Replace(url.QueryEscape(key))
+	v := strings.Replace(url.QueryEscape(value), "%2C", ",", -1)
+	return k + "=" + v
+}
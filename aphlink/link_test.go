@@ -0,0 +1,46 @@
+package aphlink
+
+import "testing"
+
+func TestOrder(t *testing.T) {
+	link := New("http://localhost/users").
+		Page("pagenum", "2").
+		Set("apikey", "x").
+		Sort("-name").
+		Filter("name==a").
+		Include("roles").
+		Fields("name,email").
+		Page("pagesize", "10").
+		String()
+	exlink := "http://localhost/users?fields=name,email&include=roles&filter=name%3D%3Da&sort=-name&pagenum=2&pagesize=10&apikey=x"
+	if link != exlink {
+		t.Fatalf("expected link %s actual %s", exlink, link)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	link := New("http://localhost/users").
+		Filter(`label=="a b";status=in=(x&y,z)`).
+		Page("page[number]", "5").
+		Page("page[size]", "10").
+		String()
+	exlink := "http://localhost/users?filter=label%3D%3D%22a+b%22%3Bstatus%3Din%3D%28x%26y,z%29&page[number]=5&page[size]=10"
+	if link != exlink {
+		t.Fatalf("expected link %s actual %s", exlink, link)
+	}
+}
+
+func TestExistingQuery(t *testing.T) {
+	b := New("http://localhost/users?pagesize=10&token=a%26b&filter=name%3D%3Da&pagenum=1")
+	link := b.Clone().Page("pagenum", "3").String()
+	exlink := "http://localhost/users?filter=name%3D%3Da&pagesize=10&pagenum=3&token=a%26b"
+	if link != exlink {
+		t.Fatalf("expected link %s actual %s", exlink, link)
+	}
+	if b.String() == link {
+		t.Fatal("expected the cloned builder to be independent")
+	}
+	if l := New("http://localhost/users").Filter("").String(); l != "http://localhost/users" {
+		t.Fatalf("expected link without query string, actual %s", l)
+	}
+}
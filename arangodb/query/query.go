@@ -2,19 +2,10 @@ package query
 
 import (
 	"fmt"
-	"regexp"
-	"strings"
 
-	"github.com/jinzhu/now"
+	"github.com/dictyBase/apihelpers/aphfilter"
 )
 
-// regex to capture all variations of filter string
-var qre = regexp.MustCompile(`(\w+)(\=\=|\!\=|\=\=\=|\!\=\=|\~|\!\~|>|<|>\=|\=<|\$\=\=|\$\>|\$\>\=|\$\<|\$\<\=)([\w-]+)(\,|\;)?`)
-
-// regex to capture all variations of date string
-// https://play.golang.org/p/NzeBmlQh13v
-var dre = regexp.MustCompile(`^\d{4}\-(0[1-9]|1[012])$|^\d{4}$|^\d{4}\-(0[1-9]|1[012])\-(0[1-9]|[12][0-9]|3[01])$`)
-
 // Filter is a container for filter parameters
 type Filter struct {
 	// Field of the object on which the filter will be applied
@@ -25,148 +16,102 @@ type Filter struct {
 	Value string
 	// Logic for combining multiple filter expressions, usually "AND" or "OR"
 	Logic string
-}
-
-func getOperatorMap() map[string]string {
-	return map[string]string{
-		"==":  "==",
-		"===": "==",
-		"!=":  "!=",
-		">":   ">",
-		"<":   "<",
-		">=":  ">=",
-		"<=":  "<=",
-		"~":   "=~",
-		"!~":  "!~",
-		"$==": "==",
-		"$>":  ">",
-		"$<":  "<",
-		"$>=": ">=",
-		"$<=": "<=",
-	}
-}
-
-// map values that are predefined as dates
-func getDateOperatorMap() map[string]string {
-	return map[string]string{
-		"$==": "==",
-		"$>":  ">",
-		"$<":  "<",
-		"$>=": ">=",
-		"$<=": "<=",
-	}
+	// Values of an in or out list
+	Values []string
+	// Whether the value was given as a quoted string
+	Quoted bool
 }
 
 // ParseFilterString parses a predefined filter string to Filter
 // structure. The filter string specification is defined in
-// corresponding protocol buffer definition.
+// corresponding protocol buffer definition and its grammar is shared
+// with the other backends through the aphfilter package. The flat list of
+// filters cannot keep the grouping of parenthesized expressions, so such a
+// filter string is an error, it has to be parsed with aphfilter.Parse and
+// used with GenAQLFilterExprStatement instead.
 func ParseFilterString(fstr string) ([]*Filter, error) {
 	// create slice that will contain Filter structs
 	var filters []*Filter
-	// get all regex matches for fstr
-	m := qre.FindAllStringSubmatch(fstr, -1)
-	// if no matches, return empty slice
-	if len(m) == 0 {
+	if len(fstr) == 0 {
 		return filters, nil
 	}
-	// get map of all allowed operators
-	omap := getOperatorMap()
+	expr, err := aphfilter.Parse(fstr)
+	if err != nil {
+		return filters, err
+	}
+	if expr.IsGrouped() {
+		return filters, fmt.Errorf("grouped filter %s cannot be parsed to a list of filters", fstr)
+	}
 	// loop through separate items from fstr string
-	for _, n := range m {
-		// if no operator found in map, return slice and throw error
-		if _, ok := omap[n[2]]; !ok {
-			return filters, fmt.Errorf("filter operator %s not allowed", n[2])
-		}
-		// initialize Filter container with appropriate data
-		f := &Filter{
-			Field:    n[1],
-			Operator: n[2],
-			Value:    n[3],
-		}
-		if len(n) == 5 {
-			f.Logic = n[4]
-		}
-		// add this Filter to slice
-		filters = append(filters, f)
+	for _, f := range expr.Leaves() {
+		filters = append(filters, &Filter{
+			Field:    f.Field,
+			Operator: string(f.Operator),
+			Value:    f.Value,
+			Logic:    f.Logic,
+			Values:   f.Values,
+			Quoted:   f.Quoted,
+		})
 	}
 	// return slice of Filter structs
 	return filters, nil
 }
 
 // GenAQLFilterStatement generates an AQL(arangodb query language) compatible
-// filter query statement
+// filter query statement where the values are written as AQL literals
 func GenAQLFilterStatement(fmap map[string]string, filters []*Filter) (string, error) {
-	// set map for logic
-	lmap := map[string]string{",": "OR", ";": "AND"}
-	// get map of all allowed operators
-	omap := getOperatorMap()
-	// get map of all date operators
-	dmap := getDateOperatorMap()
-	// initialize variable for a string builder
-	var clause strings.Builder
-	// write FILTER to this string
-	clause.WriteString("FILTER ")
-	// loop over items in filters slice
-	for _, f := range filters {
-		// check if operator is for a date
-		if _, ok := dmap[f.Operator]; ok {
-			// validate date format
-			d, err := dateValidator(f.Value)
-			if err != nil {
-				return "could not convert date", err
-			}
-			// write time conversion into AQL query
-			clause.WriteString(
-				fmt.Sprintf(
-					"%s %s DATE_ISO8601('%s')",
-					fmap[f.Field],
-					omap[f.Operator],
-					d,
-				),
-			)
-			// if there's logic, write that too
-			if len(f.Logic) != 0 {
-				clause.WriteString(fmt.Sprintf(" %s ", lmap[f.Logic]))
-			}
-		} else {
-			// write the rest of AQL statement based on non-date data
-			clause.WriteString(
-				fmt.Sprintf(
-					"%s %s %s",
-					fmap[f.Field],
-					omap[f.Operator],
-					checkAndQuote(f.Operator, f.Value),
-				),
-			)
-			// if there's logic, write that too
-			if len(f.Logic) != 0 {
-				clause.WriteString(fmt.Sprintf(" %s ", lmap[f.Logic]))
-			}
-		}
-	}
-	// return the string
-	return clause.String(), nil
+	return GenAQLFilterExprStatement(fmap, toFilterExpr(filters))
 }
 
-// check if operator is used for a string
-func checkAndQuote(op, value string) string {
-	if op == "===" || op == "!==" || op == "=~" || op == "!~" {
-		return fmt.Sprintf("'%s'", value)
+// GenAQLFilterExprStatement generates an AQL(arangodb query language)
+// compatible filter query statement from the expression tree keeping its
+// grouping, the values are written as AQL literals
+func GenAQLFilterExprStatement(fmap map[string]string, expr *aphfilter.Expr) (string, error) {
+	r := aphfilter.NewAQLRenderer(fmap)
+	r.Inline = true
+	clause, err := aphfilter.Render(expr, r)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate filter statement %s", err)
+	}
+	if len(clause) == 0 {
+		return "", nil
 	}
-	return value
+	return fmt.Sprintf("FILTER %s", clause), nil
 }
 
-func dateValidator(date string) (string, error) {
-	// get all regex matches for date
-	m := dre.FindString(date)
-	if len(m) == 0 {
-		return "", fmt.Errorf("invalid date")
-	}
-	// grab valid date and parse to time object
-	_, err := now.Parse(m)
+// GenAQLFilterStatementWithBindVars generates an AQL(arangodb query language)
+// compatible filter query statement along with the bind variables for the
+// values
+func GenAQLFilterStatementWithBindVars(fmap map[string]string, filters []*Filter) (string, map[string]interface{}, error) {
+	return GenAQLFilterExprStatementWithBindVars(fmap, toFilterExpr(filters))
+}
+
+// GenAQLFilterExprStatementWithBindVars generates an AQL(arangodb query
+// language) compatible filter query statement from the expression tree
+// keeping its grouping, along with the bind variables for the values
+func GenAQLFilterExprStatementWithBindVars(fmap map[string]string, expr *aphfilter.Expr) (string, map[string]interface{}, error) {
+	clause, vars, err := aphfilter.ToAQL(fmap, expr)
 	if err != nil {
-		return "could not parse date string", err
+		return "", vars, fmt.Errorf("unable to generate filter statement %s", err)
+	}
+	if len(clause) == 0 {
+		return "", vars, nil
+	}
+	return fmt.Sprintf("FILTER %s", clause), vars, nil
+}
+
+// toFilterExpr converts the filters to an expression tree
+func toFilterExpr(filters []*Filter) *aphfilter.Expr {
+	var fl []*aphfilter.Filter
+	for _, f := range filters {
+		fl = append(fl, &aphfilter.Filter{
+			Field:    f.Field,
+			Operator: aphfilter.Operator(f.Operator),
+			Value:    f.Value,
+			Values:   f.Values,
+			Quoted:   f.Quoted,
+			Logic:    f.Logic,
+		})
 	}
-	// if date is valid, return the original string since it matches AQL input format
-	return m, nil
+	return aphfilter.FromList(fl)
 }
@@ -0,0 +1,53 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dictyBase/apihelpers/aphfilter"
+)
+
+var fmap = map[string]string{"a": "d.a", "b": "d.b", "c": "d.c"}
+
+func TestParseFilterStringGrouped(t *testing.T) {
+	if _, err := ParseFilterString("(a==1,b==2);c==3"); err == nil {
+		t.Fatal("expected error for grouped filter")
+	}
+	filters, err := ParseFilterString("(a==1;b==2),c==3")
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	stmt, err := GenAQLFilterStatement(fmap, filters)
+	if err != nil {
+		t.Fatalf("error in generating filter statement %s", err)
+	}
+	if exstmt := "FILTER (d.a == 1 AND d.b == 2) OR d.c == 3"; stmt != exstmt {
+		t.Fatalf("expected statement %s actual %s", exstmt, stmt)
+	}
+}
+
+func TestGenAQLFilterExprStatement(t *testing.T) {
+	expr, err := aphfilter.Parse("(a==1,b==2);c==3")
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	stmt, err := GenAQLFilterExprStatement(fmap, expr)
+	if err != nil {
+		t.Fatalf("error in generating filter statement %s", err)
+	}
+	if exstmt := "FILTER (d.a == 1 OR d.b == 2) AND d.c == 3"; stmt != exstmt {
+		t.Fatalf("expected statement %s actual %s", exstmt, stmt)
+	}
+	stmt, vars, err := GenAQLFilterExprStatementWithBindVars(fmap, expr)
+	if err != nil {
+		t.Fatalf("error in generating filter statement %s", err)
+	}
+	exstmt := "FILTER (d.a == @filter1 OR d.b == @filter2) AND d.c == @filter3"
+	if stmt != exstmt {
+		t.Fatalf("expected statement %s actual %s", exstmt, stmt)
+	}
+	exvars := map[string]interface{}{"filter1": int64(1), "filter2": int64(2), "filter3": int64(3)}
+	if !reflect.DeepEqual(vars, exvars) {
+		t.Fatalf("expected bind vars %v actual %v", exvars, vars)
+	}
+}
@@ -0,0 +1,47 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	gnats "github.com/nats-io/go-nats"
+)
+
+// flushTimeout is the time to wait for the server roundtrip of the health
+// check when the context does not have any deadline
+const flushTimeout = 2 * time.Second
+
+// checkConn verifies the connection with a roundtrip to the server
+func checkConn(ctx context.Context, conn *gnats.Conn) error {
+	if conn == nil {
+		return errors.New("nats connection is not established")
+	}
+	if !conn.IsConnected() {
+		return errors.New("nats connection is not connected")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		return conn.FlushTimeout(flushTimeout)
+	}
+	return conn.FlushWithContext(ctx)
+}
+
+// Check verifies the connection of the publisher
+func (p *natsPublisher) Check(ctx context.Context) error {
+	return checkConn(ctx, p.conn)
+}
+
+// Check verifies the connection of the subscriber
+func (s *natsSubscriber) Check(ctx context.Context) error {
+	return checkConn(ctx, s.conn)
+}
+
+// Check verifies the connection of the request
+func (r *natsRequest) Check(ctx context.Context) error {
+	return checkConn(ctx, r.conn)
+}
+
+// Check verifies the connection of the reply
+func (r *natsReply) Check(ctx context.Context) error {
+	return checkConn(ctx, r.conn)
+}
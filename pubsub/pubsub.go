@@ -70,3 +70,10 @@ type Reply interface {
 	// Stop will initiate a graceful shutdown of the subscriber connection.
 	Stop() error
 }
+
+// Checker is implemented by the publishers, subscribers, requests and replies
+// that could report the health of their connection
+type Checker interface {
+	// Check returns an error if the connection is not usable
+	Check(context.Context) error
+}
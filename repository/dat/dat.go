@@ -0,0 +1,50 @@
+// Package dat implements the repository interface on top of the sqlx-runner
+// of dat.v2.
+package dat
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dictyBase/apihelpers/aphfilter"
+	"github.com/dictyBase/apihelpers/repository"
+	"github.com/dictyBase/apihelpers/repository/sqldb"
+	"gopkg.in/mgutz/dat.v2/sqlx-runner"
+)
+
+type datRepository struct {
+	dbh   *runner.DB
+	table *repository.Table
+	// the records are handled through the database/sql handle of the
+	// runner
+	repository.Repository
+}
+
+// NewRepository creates a repository for the table using the dat database
+// handle, the counts are queried through the dat builder whereas the rest
+// is delegated to the database/sql adapter
+func NewRepository(dbh *runner.DB, table *repository.Table) repository.Repository {
+	return &datRepository{
+		dbh:        dbh,
+		table:      table,
+		Repository: sqldb.NewRepository(dbh.DB, table),
+	}
+}
+
+// Count counts the records that match the filter
+func (r *datRepository) Count(ctx context.Context, filter *aphfilter.Expr) (int64, error) {
+	var count int64
+	where, values, err := sqldb.WhereClause(r.table, filter)
+	if err != nil {
+		return count, err
+	}
+	if len(where) == 0 {
+		err = r.dbh.Select("COUNT(*)").From(r.table.Name).QueryScalar(&count)
+		return count, err
+	}
+	err = r.dbh.Select("COUNT(*)").
+		From(r.table.Name).
+		Scope(strings.TrimSpace(where), values...).
+		QueryScalar(&count)
+	return count, err
+}
@@ -0,0 +1,333 @@
+// Package memory implements the repository interface with an in-memory
+// store, it is meant to be used in tests.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dictyBase/apihelpers/aphfilter"
+	"github.com/dictyBase/apihelpers/repository"
+)
+
+type memRepository struct {
+	mu      sync.RWMutex
+	table   *repository.Table
+	records []repository.Record
+	lastID  int64
+}
+
+// NewRepository creates an empty repository for the table, the records
+// without any identifier get an auto incremented integer identifier on
+// creation
+func NewRepository(table *repository.Table) repository.Repository {
+	return &memRepository{table: table}
+}
+
+// Count counts the records that match the filter
+func (r *memRepository) Count(ctx context.Context, filter *aphfilter.Expr) (int64, error) {
+	recs, err := r.filter(filter)
+	return int64(len(recs)), err
+}
+
+// List lists the records that match the query
+func (r *memRepository) List(ctx context.Context, q *repository.Query) ([]repository.Record, error) {
+	if q == nil {
+		q = &repository.Query{}
+	}
+	recs, err := r.filter(q.Filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(recs, func(i, j int) bool {
+		for _, s := range q.Sort {
+			c := compare(recs[i][s.Column], recs[j][s.Column])
+			if c == 0 {
+				continue
+			}
+			if s.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	if q.Offset >= int64(len(recs)) {
+		return nil, nil
+	}
+	recs = recs[q.Offset:]
+	if q.Limit > 0 && q.Limit < int64(len(recs)) {
+		recs = recs[:q.Limit]
+	}
+	for i, rec := range recs {
+		recs[i] = project(rec, q.Columns)
+	}
+	return recs, nil
+}
+
+// Get gets a single record by its identifier
+func (r *memRepository) Get(ctx context.Context, id interface{}) (repository.Record, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i := r.index(id)
+	if i < 0 {
+		return nil, repository.ErrNotFound
+	}
+	return project(r.records[i], nil), nil
+}
+
+// Create creates a new record and returns it as stored
+func (r *memRepository) Create(ctx context.Context, rec repository.Record) (repository.Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec = project(rec, nil)
+	id, ok := rec[r.table.IDColumn]
+	if !ok || id == nil {
+		r.lastID++
+		rec[r.table.IDColumn] = r.lastID
+	} else if r.index(id) >= 0 {
+		return nil, fmt.Errorf("record with id %v already exists in %s", id, r.table.Name)
+	} else if n, err := strconv.ParseInt(fmt.Sprint(id), 10, 64); err == nil && n > r.lastID {
+		// the generated identifiers continue after the explicit ones, which
+		// are compared in their string form as well
+		r.lastID = n
+	}
+	r.records = append(r.records, rec)
+	return project(rec, nil), nil
+}
+
+// Update updates the given columns of a record and returns it as stored
+func (r *memRepository) Update(ctx context.Context, id interface{}, rec repository.Record) (repository.Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 {
+		return nil, repository.ErrNotFound
+	}
+	for k, v := range rec {
+		r.records[i][k] = v
+	}
+	return project(r.records[i], nil), nil
+}
+
+// Delete deletes a record by its identifier
+func (r *memRepository) Delete(ctx context.Context, id interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 {
+		return repository.ErrNotFound
+	}
+	r.records = append(r.records[:i], r.records[i+1:]...)
+	return nil
+}
+
+// index returns the position of the record, the identifiers are compared in
+// their string form so that an integer identifier matches its string
+func (r *memRepository) index(id interface{}) int {
+	for i, rec := range r.records {
+		if fmt.Sprint(rec[r.table.IDColumn]) == fmt.Sprint(id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// filter returns the copies of the records that match the filter
+func (r *memRepository) filter(expr *aphfilter.Expr) ([]repository.Record, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var recs []repository.Record
+	for _, rec := range r.records {
+		if !expr.IsEmpty() {
+			ok, err := r.match(rec, expr)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		recs = append(recs, project(rec, nil))
+	}
+	return recs, nil
+}
+
+// match evaluates the expression tree for the record
+func (r *memRepository) match(rec repository.Record, expr *aphfilter.Expr) (bool, error) {
+	if expr.IsLeaf() {
+		col, ok := r.table.FilterColumns[expr.Filter.Field]
+		if !ok {
+			return false, fmt.Errorf("no mapping found for filter field %s", expr.Filter.Field)
+		}
+		return matchFilter(rec[col], expr.Filter)
+	}
+	for _, c := range expr.Children {
+		ok, err := r.match(rec, c)
+		if err != nil {
+			return false, err
+		}
+		if expr.Logic == aphfilter.Or && ok {
+			return true, nil
+		}
+		if expr.Logic == aphfilter.And && !ok {
+			return false, nil
+		}
+	}
+	return expr.Logic == aphfilter.And, nil
+}
+
+// matchFilter evaluates a single filter with the same semantics as the
+// postgresql renderer
+func matchFilter(v interface{}, f *aphfilter.Filter) (bool, error) {
+	if f.Operator == aphfilter.Null {
+		return (v == nil) == (f.Value == "true"), nil
+	}
+	if v == nil {
+		return false, nil
+	}
+	switch f.Operator {
+	case aphfilter.In, aphfilter.NotIn:
+		found := false
+		for _, fv := range f.Values {
+			if compare(v, aphfilter.TypedValue(f, fv)) == 0 {
+				found = true
+				break
+			}
+		}
+		return found == (f.Operator == aphfilter.In), nil
+	case aphfilter.Contains, aphfilter.NotContains:
+		found := strings.Contains(
+			strings.ToLower(fmt.Sprint(v)),
+			strings.ToLower(f.Value),
+		)
+		return found == (f.Operator == aphfilter.Contains), nil
+	case aphfilter.Match, aphfilter.NotMatch:
+		re, err := regexp.Compile(f.Value)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(fmt.Sprint(v)) == (f.Operator == aphfilter.Match), nil
+	case aphfilter.Equal, aphfilter.StrictEqual:
+		return fmt.Sprint(v) == f.Value, nil
+	case aphfilter.NotEqual, aphfilter.StrictNotEqual:
+		return fmt.Sprint(v) != f.Value, nil
+	case aphfilter.Greater, aphfilter.GreaterOrEqual, aphfilter.Less, aphfilter.LessOrEqual:
+		return compareOp(string(f.Operator), compare(v, aphfilter.TypedValue(f, f.Value))), nil
+	case aphfilter.DateEqual, aphfilter.DateGreater, aphfilter.DateGreaterOrEqual,
+		aphfilter.DateLess, aphfilter.DateLessOrEqual:
+		d, err := aphfilter.ParseDate(f.Value)
+		if err != nil {
+			return false, err
+		}
+		op := strings.TrimPrefix(string(f.Operator), "$")
+		return compareOp(op, compare(v, d)), nil
+	}
+	return false, fmt.Errorf("filter operator %s is not supported", f.Operator)
+}
+
+func compareOp(op string, c int) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// compare compares numbers numerically, times chronologically and the rest
+// as strings, nil is smaller than any other value
+func compare(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// project copies the given columns of the record, all of them if the list
+// is empty
+func project(rec repository.Record, columns []string) repository.Record {
+	cp := make(repository.Record)
+	if len(columns) == 0 {
+		for k, v := range rec {
+			cp[k] = v
+		}
+		return cp
+	}
+	for _, c := range columns {
+		if v, ok := rec[c]; ok {
+			cp[c] = v
+		}
+	}
+	return cp
+}
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dictyBase/apihelpers/aphfilter"
+	"github.com/dictyBase/apihelpers/repository"
+)
+
+var table = &repository.Table{
+	Name:     "stock",
+	IDColumn: "id",
+	FilterColumns: map[string]string{
+		"name":       "name",
+		"count":      "count",
+		"created_at": "created_at",
+	},
+}
+
+func seed(t *testing.T) repository.Repository {
+	repo := NewRepository(table)
+	for _, rec := range []repository.Record{
+		{"name": "sadA", "count": 3, "created_at": time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"name": "pten", "count": 10, "created_at": time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"name": "sadB", "count": 7, "created_at": nil},
+	} {
+		if _, err := repo.Create(context.Background(), rec); err != nil {
+			t.Fatalf("error in creating record %s", err)
+		}
+	}
+	return repo
+}
+
+func TestCount(t *testing.T) {
+	repo := seed(t)
+	cases := map[string]int64{
+		"":                                 3,
+		"name=@SAD":                        2,
+		"count>3":                          2,
+		"(name==sadA,name==pten);count<=5": 1,
+		"created_at$>=2019":                1,
+		"created_at=null=true":             1,
+		"name=in=(sadA,sadB);name!=sadB":   1,
+		"name=out=(sadA);created_at$<2019": 0,
+	}
+	for fstr, count := range cases {
+		var expr *aphfilter.Expr
+		if len(fstr) > 0 {
+			e, err := aphfilter.Parse(fstr)
+			if err != nil {
+				t.Fatalf("error in parsing filter %s %s", fstr, err)
+			}
+			expr = e
+		}
+		c, err := repo.Count(context.Background(), expr)
+		if err != nil {
+			t.Fatalf("error in counting %s %s", fstr, err)
+		}
+		if c != count {
+			t.Errorf("expected count %d for %q, got %d", count, fstr, c)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	repo := seed(t)
+	recs, err := repo.List(context.Background(), &repository.Query{
+		Sort:    []*repository.Sort{{Column: "count", Desc: true}},
+		Columns: []string{"name"},
+		Limit:   2,
+		Offset:  1,
+	})
+	if err != nil {
+		t.Fatalf("error in listing %s", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(recs))
+	}
+	if recs[0]["name"] != "sadB" || recs[1]["name"] != "sadA" {
+		t.Fatalf("unexpected order %v", recs)
+	}
+	if _, ok := recs[0]["count"]; ok {
+		t.Fatalf("expected only the name column, got %v", recs[0])
+	}
+}
+
+func TestCrud(t *testing.T) {
+	repo := seed(t)
+	ctx := context.Background()
+	rec, err := repo.Get(ctx, "2")
+	if err != nil {
+		t.Fatalf("error in getting record %s", err)
+	}
+	if rec["name"] != "pten" {
+		t.Fatalf("expected record pten, got %v", rec["name"])
+	}
+	rec, err = repo.Update(ctx, 2, repository.Record{"count": 11})
+	if err != nil {
+		t.Fatalf("error in updating record %s", err)
+	}
+	if rec["count"] != 11 || rec["name"] != "pten" {
+		t.Fatalf("unexpected updated record %v", rec)
+	}
+	if err := repo.Delete(ctx, 2); err != nil {
+		t.Fatalf("error in deleting record %s", err)
+	}
+	if _, err := repo.Get(ctx, 2); err != repository.ErrNotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if err := repo.Delete(ctx, 2); err != repository.ErrNotFound {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestCreateExplicitID(t *testing.T) {
+	repo := NewRepository(table)
+	ctx := context.Background()
+	if _, err := repo.Create(ctx, repository.Record{"id": int64(5), "name": "sadA"}); err != nil {
+		t.Fatalf("error in creating record %s", err)
+	}
+	rec, err := repo.Create(ctx, repository.Record{"name": "pten"})
+	if err != nil {
+		t.Fatalf("error in creating record %s", err)
+	}
+	if rec["id"] != int64(6) {
+		t.Fatalf("expected generated id 6, got %v", rec["id"])
+	}
+	if _, err := repo.Create(ctx, repository.Record{"id": 3, "name": "sadB"}); err != nil {
+		t.Fatalf("error in creating record %s", err)
+	}
+	rec, err = repo.Create(ctx, repository.Record{"name": "sadC"})
+	if err != nil {
+		t.Fatalf("error in creating record %s", err)
+	}
+	if rec["id"] != int64(7) {
+		t.Fatalf("expected generated id 7, got %v", rec["id"])
+	}
+	if n, err := repo.Count(ctx, nil); err != nil || n != 4 {
+		t.Fatalf("expected 4 records, got %d %v", n, err)
+	}
+}
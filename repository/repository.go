@@ -0,0 +1,70 @@
+// Package repository provides a storage agnostic interface for the records
+// of a resource, which is implemented by the dat, database/sql and in-memory
+// adapters of its subpackages.
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/dictyBase/apihelpers/aphfilter"
+)
+
+// ErrNotFound is returned when the record is absent in the storage, it is
+// the same error as that of database/sql so that both are handled alike
+var ErrNotFound = sql.ErrNoRows
+
+// Record is a single record of a resource keyed by the storage columns
+type Record map[string]interface{}
+
+// Sort is a single sort criterion
+type Sort struct {
+	// Column on which the records are sorted
+	Column string
+	// Desc sorts in the descending order
+	Desc bool
+}
+
+// Query is the criteria for listing the records, the zero value lists all
+// the records with all their columns
+type Query struct {
+	// Filter is the filter expression, its fields are mapped to the storage
+	// columns by the repository
+	Filter *aphfilter.Expr
+	// Sort criteria in the order of precedence
+	Sort []*Sort
+	// Columns to fetch, all of them if it is empty
+	Columns []string
+	// Limit is the maximum number of records, zero means no limit
+	Limit int64
+	// Offset is the number of records to skip
+	Offset int64
+}
+
+// Table describes the storage of a resource
+type Table struct {
+	// Name of the table or collection
+	Name string
+	// IDColumn is the column of the record identifier
+	IDColumn string
+	// FilterColumns maps the filter fields to the storage columns
+	FilterColumns map[string]string
+}
+
+// Repository is a generic interface to encapsulate the storage of the
+// records of a resource
+type Repository interface {
+	// Count counts the records that match the filter, all the records are
+	// counted for a nil filter
+	Count(context.Context, *aphfilter.Expr) (int64, error)
+	// List lists the records that match the query
+	List(context.Context, *Query) ([]Record, error)
+	// Get gets a single record by its identifier
+	Get(context.Context, interface{}) (Record, error)
+	// Create creates a new record and returns it as stored
+	Create(context.Context, Record) (Record, error)
+	// Update updates the given columns of a record and returns it as stored
+	Update(context.Context, interface{}, Record) (Record, error)
+	// Delete deletes a record by its identifier
+	Delete(context.Context, interface{}) error
+}
@@ -0,0 +1,279 @@
+// Package sqldb implements the repository interface on top of database/sql
+// for postgresql compatible databases.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dictyBase/apihelpers/aphfilter"
+	"github.com/dictyBase/apihelpers/repository"
+)
+
+// Queryer is implemented by *sql.DB, *sql.Tx and *sql.Conn
+type Queryer interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+type sqlRepository struct {
+	db    Queryer
+	table *repository.Table
+}
+
+// NewRepository creates a repository for the table using the database
+// handle, which could also be a transaction
+func NewRepository(db Queryer, table *repository.Table) repository.Repository {
+	return &sqlRepository{db: db, table: table}
+}
+
+// Count counts the records that match the filter
+func (r *sqlRepository) Count(ctx context.Context, filter *aphfilter.Expr) (int64, error) {
+	var count int64
+	where, args, err := WhereClause(r.table, filter)
+	if err != nil {
+		return count, err
+	}
+	err = r.db.QueryRowContext(
+		ctx,
+		fmt.Sprintf("SELECT COUNT(*) FROM %s%s", QuoteIdent(r.table.Name), where),
+		args...,
+	).Scan(&count)
+	return count, err
+}
+
+// List lists the records that match the query
+func (r *sqlRepository) List(ctx context.Context, q *repository.Query) ([]repository.Record, error) {
+	if q == nil {
+		q = &repository.Query{}
+	}
+	query, args, err := SelectQuery(r.table, q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecords(rows)
+}
+
+// Get gets a single record by its identifier
+func (r *sqlRepository) Get(ctx context.Context, id interface{}) (repository.Record, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT * FROM %s WHERE %s = $1",
+			QuoteIdent(r.table.Name), QuoteIdent(r.table.IDColumn),
+		),
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecord(rows)
+}
+
+// Create creates a new record and returns it as stored
+func (r *sqlRepository) Create(ctx context.Context, rec repository.Record) (repository.Record, error) {
+	query, args := InsertQuery(r.table, rec)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecord(rows)
+}
+
+// Update updates the given columns of a record and returns it as stored
+func (r *sqlRepository) Update(ctx context.Context, id interface{}, rec repository.Record) (repository.Record, error) {
+	if len(rec) == 0 {
+		return r.Get(ctx, id)
+	}
+	cols, args := recordColumns(rec)
+	set := make([]string, len(cols))
+	for i, c := range cols {
+		set[i] = fmt.Sprintf("%s = $%d", QuoteIdent(c), i+1)
+	}
+	rows, err := r.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			"UPDATE %s SET %s WHERE %s = $%d RETURNING *",
+			QuoteIdent(r.table.Name), strings.Join(set, ", "),
+			QuoteIdent(r.table.IDColumn), len(cols)+1,
+		),
+		append(args, id)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecord(rows)
+}
+
+// Delete deletes a record by its identifier
+func (r *sqlRepository) Delete(ctx context.Context, id interface{}) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			"DELETE FROM %s WHERE %s = $1",
+			QuoteIdent(r.table.Name), QuoteIdent(r.table.IDColumn),
+		),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// InsertQuery generates the insert statement of the record with numbered
+// placeholders for its values, the columns that are absent in the record get
+// their default values
+func InsertQuery(table *repository.Table, rec repository.Record) (string, []interface{}) {
+	if len(rec) == 0 {
+		return fmt.Sprintf("INSERT INTO %s DEFAULT VALUES RETURNING *", QuoteIdent(table.Name)), nil
+	}
+	cols, args := recordColumns(rec)
+	ph := make([]string, len(cols))
+	for i := range cols {
+		ph[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) RETURNING *",
+		QuoteIdent(table.Name), quoteIdents(cols), strings.Join(ph, ", "),
+	), args
+}
+
+// WhereClause generates the where clause of the filter with numbered
+// placeholders, it is empty for an empty or nil filter. The filter columns
+// are quoted like every other identifier.
+func WhereClause(table *repository.Table, filter *aphfilter.Expr) (string, []interface{}, error) {
+	if filter.IsEmpty() {
+		return "", nil, nil
+	}
+	columns := make(map[string]string)
+	for field, col := range table.FilterColumns {
+		columns[field] = QuoteIdent(col)
+	}
+	clause, args, err := aphfilter.ToPostgres(columns, filter)
+	if err != nil {
+		return "", args, err
+	}
+	return " WHERE " + clause, args, nil
+}
+
+// SelectQuery generates the select statement of the query with numbered
+// placeholders for the filter values
+func SelectQuery(table *repository.Table, q *repository.Query) (string, []interface{}, error) {
+	cols := "*"
+	if len(q.Columns) > 0 {
+		cols = quoteIdents(q.Columns)
+	}
+	where, args, err := WhereClause(table, q.Filter)
+	if err != nil {
+		return "", args, err
+	}
+	var query strings.Builder
+	fmt.Fprintf(&query, "SELECT %s FROM %s%s", cols, QuoteIdent(table.Name), where)
+	if len(q.Sort) > 0 {
+		order := make([]string, len(q.Sort))
+		for i, s := range q.Sort {
+			order[i] = QuoteIdent(s.Column)
+			if s.Desc {
+				order[i] += " DESC"
+			}
+		}
+		fmt.Fprintf(&query, " ORDER BY %s", strings.Join(order, ", "))
+	}
+	if q.Limit > 0 {
+		fmt.Fprintf(&query, " LIMIT %d", q.Limit)
+	}
+	if q.Offset > 0 {
+		fmt.Fprintf(&query, " OFFSET %d", q.Offset)
+	}
+	return query.String(), args, nil
+}
+
+// QuoteIdent quotes an identifier, a qualified identifier(table.column) is
+// quoted part by part
+func QuoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = `"` + strings.Replace(p, `"`, `""`, -1) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = QuoteIdent(n)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// recordColumns returns the columns of the record in a stable order along
+// with their values
+func recordColumns(rec repository.Record) ([]string, []interface{}) {
+	var cols []string
+	for k := range rec {
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
+	args := make([]interface{}, len(cols))
+	for i, c := range cols {
+		args[i] = rec[c]
+	}
+	return cols, args
+}
+
+func scanRecords(rows *sql.Rows) ([]repository.Record, error) {
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var recs []repository.Record
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return recs, err
+		}
+		rec := make(repository.Record)
+		for i, c := range cols {
+			// text values are returned as byte slices by most of the drivers
+			if b, ok := values[i].([]byte); ok {
+				rec[c] = string(b)
+			} else {
+				rec[c] = values[i]
+			}
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
+
+func scanRecord(rows *sql.Rows) (repository.Record, error) {
+	recs, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return recs[0], nil
+}
@@ -0,0 +1,63 @@
+package sqldb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dictyBase/apihelpers/aphfilter"
+	"github.com/dictyBase/apihelpers/repository"
+)
+
+func TestSelectQuery(t *testing.T) {
+	table := &repository.Table{
+		Name:          "stock.strain",
+		IDColumn:      "id",
+		FilterColumns: map[string]string{"name": "name", "count": "count"},
+	}
+	expr, err := aphfilter.Parse("name==sadA,count>3")
+	if err != nil {
+		t.Fatalf("error in parsing filter %s", err)
+	}
+	query, args, err := SelectQuery(table, &repository.Query{
+		Filter:  expr,
+		Sort:    []*repository.Sort{{Column: "name"}, {Column: "count", Desc: true}},
+		Columns: []string{"id", "name"},
+		Limit:   10,
+		Offset:  20,
+	})
+	if err != nil {
+		t.Fatalf("error in generating query %s", err)
+	}
+	exq := `SELECT "id", "name" FROM "stock"."strain" WHERE "name" = $1 OR "count" > $2 ORDER BY "name", "count" DESC LIMIT 10 OFFSET 20`
+	if query != exq {
+		t.Fatalf("expected query %s, got %s", exq, query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"sadA", int64(3)}) {
+		t.Fatalf("unexpected bind values %v", args)
+	}
+	for _, filter := range []*aphfilter.Expr{nil, aphfilter.FromList(nil)} {
+		query, args, err = SelectQuery(table, &repository.Query{Filter: filter})
+		if err != nil {
+			t.Fatalf("error in generating query %s", err)
+		}
+		if query != `SELECT * FROM "stock"."strain"` || len(args) != 0 {
+			t.Fatalf("unexpected query %s %v", query, args)
+		}
+	}
+}
+
+func TestInsertQuery(t *testing.T) {
+	table := &repository.Table{Name: "stock.strain", IDColumn: "id"}
+	query, args := InsertQuery(table, repository.Record{"name": "sadA", "count": 3})
+	exq := `INSERT INTO "stock"."strain" ("count", "name") VALUES ($1, $2) RETURNING *`
+	if query != exq {
+		t.Fatalf("expected query %s, got %s", exq, query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{3, "sadA"}) {
+		t.Fatalf("unexpected bind values %v", args)
+	}
+	query, args = InsertQuery(table, repository.Record{})
+	if query != `INSERT INTO "stock"."strain" DEFAULT VALUES RETURNING *` || len(args) != 0 {
+		t.Fatalf("unexpected query %s %v", query, args)
+	}
+}